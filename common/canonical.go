@@ -0,0 +1,173 @@
+package common
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// CanonicalizeJSON returns the RFC 8785 JSON Canonicalization Scheme (JCS) encoding of v.
+func CanonicalizeJSON(v interface{}) ([]byte, error) {
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		return nil, ChainError("error marshaling json", err)
+	}
+
+	//decode into generic values so object keys can be sorted
+	decoder := json.NewDecoder(bytes.NewReader(encoded))
+	decoder.UseNumber()
+
+	var value interface{}
+	err = decoder.Decode(&value)
+	if err != nil {
+		return nil, ChainError("error decoding json", err)
+	}
+
+	buffer := new(bytes.Buffer)
+	err = writeCanonicalValue(buffer, value)
+	if err != nil {
+		return nil, ChainError("error writing canonical json", err)
+	}
+
+	return buffer.Bytes(), nil
+}
+
+func writeCanonicalValue(buffer *bytes.Buffer, value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		buffer.WriteString("null")
+	case bool:
+		buffer.WriteString(strconv.FormatBool(v))
+	case json.Number:
+		f, err := v.Float64()
+		if err != nil {
+			return ChainError("error parsing number", err)
+		}
+
+		s, err := formatCanonicalNumber(f)
+		if err != nil {
+			return err
+		}
+		buffer.WriteString(s)
+	case string:
+		writeCanonicalString(buffer, v)
+	case []interface{}:
+		buffer.WriteByte('[')
+		for i, elem := range v {
+			if i > 0 {
+				buffer.WriteByte(',')
+			}
+
+			err := writeCanonicalValue(buffer, elem)
+			if err != nil {
+				return err
+			}
+		}
+		buffer.WriteByte(']')
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+
+		//keys are sorted by their UTF-16 code units
+		sort.Slice(keys, func(i, j int) bool {
+			return compareUTF16(keys[i], keys[j]) < 0
+		})
+
+		buffer.WriteByte('{')
+		for i, key := range keys {
+			if i > 0 {
+				buffer.WriteByte(',')
+			}
+
+			writeCanonicalString(buffer, key)
+			buffer.WriteByte(':')
+
+			err := writeCanonicalValue(buffer, v[key])
+			if err != nil {
+				return err
+			}
+		}
+		buffer.WriteByte('}')
+	default:
+		return fmt.Errorf("unsupported json value type %T", value)
+	}
+
+	return nil
+}
+
+func writeCanonicalString(buffer *bytes.Buffer, s string) {
+	buffer.WriteByte('"')
+
+	for _, r := range s {
+		switch r {
+		case '"':
+			buffer.WriteString(`\"`)
+		case '\\':
+			buffer.WriteString(`\\`)
+		case '\b':
+			buffer.WriteString(`\b`)
+		case '\f':
+			buffer.WriteString(`\f`)
+		case '\n':
+			buffer.WriteString(`\n`)
+		case '\r':
+			buffer.WriteString(`\r`)
+		case '\t':
+			buffer.WriteString(`\t`)
+		default:
+			if r < 0x20 {
+				fmt.Fprintf(buffer, `\u%04x`, r)
+			} else {
+				buffer.WriteRune(r)
+			}
+		}
+	}
+
+	buffer.WriteByte('"')
+}
+
+// formatCanonicalNumber formats the number the same as ECMAScript's Number.prototype.toString.
+func formatCanonicalNumber(f float64) (string, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return "", errors.New("NaN and Infinity are not valid json numbers")
+	}
+
+	if f == 0 {
+		return "0", nil
+	}
+
+	abs := math.Abs(f)
+	if abs >= 1e21 || abs < 1e-6 {
+		s := strconv.FormatFloat(f, 'e', -1, 64)
+
+		//ECMAScript does not pad the exponent, e.g. 1e-07 is 1e-7
+		parts := strings.SplitN(s, "e", 2)
+		sign := parts[1][:1]
+		exponent := strings.TrimLeft(parts[1][1:], "0")
+
+		return parts[0] + "e" + sign + exponent, nil
+	}
+
+	return strconv.FormatFloat(f, 'f', -1, 64), nil
+}
+
+func compareUTF16(a string, b string) int {
+	ua := utf16.Encode([]rune(a))
+	ub := utf16.Encode([]rune(b))
+
+	for i := 0; i < len(ua) && i < len(ub); i++ {
+		if ua[i] != ub[i] {
+			return int(ua[i]) - int(ub[i])
+		}
+	}
+
+	return len(ua) - len(ub)
+}
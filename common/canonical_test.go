@@ -0,0 +1,191 @@
+package common
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+)
+
+// TestFormatCanonicalNumber checks the number serialization samples of RFC 8785 appendix B.
+func TestFormatCanonicalNumber(t *testing.T) {
+	for _, tc := range []struct {
+		bits     uint64
+		expected string
+	}{
+		{0x0000000000000000, "0"},
+		{0x8000000000000000, "0"},
+		{0x0000000000000001, "5e-324"},
+		{0x8000000000000001, "-5e-324"},
+		{0x7fefffffffffffff, "1.7976931348623157e+308"},
+		{0xffefffffffffffff, "-1.7976931348623157e+308"},
+		{0x4340000000000000, "9007199254740992"},
+		{0xc340000000000000, "-9007199254740992"},
+		{0x4430000000000000, "295147905179352830000"},
+		{0x44b52d02c7e14af5, "9.999999999999997e+22"},
+		{0x44b52d02c7e14af6, "1e+23"},
+		{0x44b52d02c7e14af7, "1.0000000000000001e+23"},
+		{0x444b1ae4d6e2ef4e, "999999999999999700000"},
+		{0x444b1ae4d6e2ef4f, "999999999999999900000"},
+		{0x444b1ae4d6e2ef50, "1e+21"},
+		{0x3eb0c6f7a0b5ed8c, "9.999999999999997e-7"},
+		{0x3eb0c6f7a0b5ed8d, "0.000001"},
+		{0x41b3de4355555553, "333333333.3333332"},
+		{0x41b3de4355555554, "333333333.33333325"},
+		{0x41b3de4355555555, "333333333.3333333"},
+		{0x41b3de4355555556, "333333333.3333334"},
+		{0x41b3de4355555557, "333333333.33333343"},
+		{0xbecbf647612f3696, "-0.0000033333333333333333"},
+		{0x43143ff3c1cb0959, "1424953923781206.2"},
+	} {
+		f := math.Float64frombits(tc.bits)
+
+		s, err := formatCanonicalNumber(f)
+		if err != nil {
+			t.Errorf("%016x: %v", tc.bits, err)
+			continue
+		}
+		if s != tc.expected {
+			t.Errorf("%016x: expected %s, got %s", tc.bits, tc.expected, s)
+		}
+
+		//the number is formatted the same after a round trip through encoding/json
+		canonical, err := CanonicalizeJSON(f)
+		if err != nil {
+			t.Errorf("%016x: %v", tc.bits, err)
+			continue
+		}
+		if string(canonical) != tc.expected {
+			t.Errorf("%016x: expected %s, got %s", tc.bits, tc.expected, canonical)
+		}
+	}
+
+	for _, f := range []float64{math.NaN(), math.Inf(1), math.Inf(-1)} {
+		_, err := formatCanonicalNumber(f)
+		if err == nil {
+			t.Errorf("%v: expected an error", f)
+		}
+	}
+}
+
+func TestCanonicalizeJSON(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		//RFC 8785 section 3.2.2
+		{
+			"sample",
+			`{
+				"numbers": [333333333.33333329, 1E30, 4.50, 2e-3, 0.000000000000000000000000001],
+				"string": "\u20ac$\u000F\u000aA'\u0042\u0022\u005c\\\"\/",
+				"literals": [null, true, false]
+			}`,
+			`{"literals":[null,true,false],"numbers":[333333333.3333333,1e+30,4.5,0.002,1e-27],"string":"€$\u000f\nA'B\"\\\\\"/"}`,
+		},
+		//RFC 8785 section 3.2.3, keys are sorted by UTF-16 code units so the emoji's surrogates sort before U+FB33
+		{
+			"key ordering",
+			`{
+				"\u20ac": "Euro Sign",
+				"\r": "Carriage Return",
+				"\ufb33": "Hebrew Letter Dalet With Dagesh",
+				"1": "One",
+				"\ud83d\ude00": "Emoji: Grinning Face",
+				"\u0080": "Control",
+				"\u00f6": "Latin Small Letter O With Diaeresis"
+			}`,
+			"{\"\\r\":\"Carriage Return\",\"1\":\"One\",\"\u0080\":\"Control\",\"ö\":\"Latin Small Letter O With Diaeresis\"," +
+				"\"€\":\"Euro Sign\",\"😀\":\"Emoji: Grinning Face\",\"\ufb33\":\"Hebrew Letter Dalet With Dagesh\"}",
+		},
+		{
+			"string escaping",
+			`["\u0000\u0008\u0009\u000a\u000c\u000d\u001f", "\u007f\u2028<>&", "\"\\/"]`,
+			"[\"\\u0000\\b\\t\\n\\f\\r\\u001f\",\"\u007f\u2028<>&\",\"\\\"\\\\/\"]",
+		},
+		{
+			"nested arrays and objects",
+			`{"b": [{"d": 1, "c": [2, {"f": null, "e": "x"}]}, [], [[{}]]], "a": {"z": {"y": [true]}, "x": []}}`,
+			`{"a":{"x":[],"z":{"y":[true]}},"b":[{"c":[2,{"e":"x","f":null}],"d":1},[],[[{}]]]}`,
+		},
+	} {
+		canonical, err := CanonicalizeJSON(json.RawMessage(tc.input))
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		if string(canonical) != tc.expected {
+			t.Errorf("%s: expected\n%s\ngot\n%s", tc.name, tc.expected, canonical)
+		}
+	}
+}
+
+type testSignedFields struct {
+	Name    string            `json:"name"`
+	Count   int               `json:"count"`
+	Fields  map[string]string `json:"fields"`
+	Subject Signature         `json:"subject"`
+}
+
+// testReorderedFields has the fields of testSignedFields in a different order.
+type testReorderedFields struct {
+	Subject Signature         `json:"subject"`
+	Fields  map[string]string `json:"fields"`
+	Count   int               `json:"count"`
+	Name    string            `json:"name"`
+}
+
+// TestSignatureSurvivesFieldReordering checks a canonical signature still verifies after the struct's fields are reordered.
+func TestSignatureSurvivesFieldReordering(t *testing.T) {
+	key, err := GenerateKey("ed25519")
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := NewSigner(key, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signed := testSignedFields{
+		Name:    "Alice",
+		Count:   3,
+		Fields:  map[string]string{"b": "2", "a": "1"},
+		Subject: Signature{DID: "did:example:holder"},
+	}
+	err = SignStructWithSigner(signer, &signed.Subject, &signed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(signed.Subject.Signature, SIGNATURE_VERSION_JCS) {
+		t.Fatalf("expected a %s signature, got %s", SIGNATURE_VERSION_JCS, signed.Subject.Signature)
+	}
+
+	reordered := testReorderedFields{
+		Subject: signed.Subject,
+		Fields:  signed.Fields,
+		Count:   signed.Count,
+		Name:    signed.Name,
+	}
+
+	//the encoding/json output differs, so a legacy signature would not verify
+	original, _ := json.Marshal(&testSignedFields{Name: signed.Name, Count: signed.Count, Fields: signed.Fields})
+	moved, _ := json.Marshal(&testReorderedFields{Name: signed.Name, Count: signed.Count, Fields: signed.Fields})
+	if bytes.Equal(original, moved) {
+		t.Fatal("reordering the fields did not change the encoding/json output")
+	}
+
+	err = VerifyStructSignature(key.Public(), &reordered.Subject, &reordered)
+	if err != nil {
+		t.Errorf("signature does not verify after reordering the fields: %v", err)
+	}
+
+	//changing a value still breaks the signature
+	reordered.Subject = signed.Subject
+	reordered.Count++
+	err = VerifyStructSignature(key.Public(), &reordered.Subject, &reordered)
+	if err == nil {
+		t.Error("signature verifies after changing a field")
+	}
+}
@@ -1,12 +1,16 @@
 package common
 
 import (
-	"errors"
+	"encoding/json"
+	"fmt"
 	"log"
+	"time"
 )
 
 type Signature struct {
 	DID       string `json:"did"`
+	Algorithm string `json:"alg,omitempty"`
+	KeyID     string `json:"kid,omitempty"`
 	Signature string `json:"signature,omitempty"`
 }
 
@@ -15,6 +19,12 @@ type PresentationField struct {
 	Type string `json:"type"`
 }
 
+type CredentialRequirement struct {
+	CredType string   `json:"cred_type"`
+	Issuers  []string `json:"issuers"`
+	Fields   []string `json:"fields,omitempty"`
+}
+
 type PresentationRequest struct {
 	Type       string `json:"type"`
 	ServiceURL string `json:"service_url"`
@@ -24,20 +34,118 @@ type PresentationRequest struct {
 	Description string              `json:"description"`
 	Fields      []PresentationField `json:"fields,omitempty"`
 
-	Issuer string    `json:"issuer,omitempty"`
-	Entity Signature `json:"entity"`
+	Requirements []CredentialRequirement `json:"requirements,omitempty"`
+	Entity       Signature               `json:"entity"`
+
+	Nonce    string `json:"nonce,omitempty"`
+	Audience string `json:"audience,omitempty"`
 }
 
 type VerifiableCredential struct {
+	ID          string            `json:"id,omitempty"`
 	CredType    string            `json:"cred_type"`
 	Credentials map[string]string `json:"credentials"`
 
+	//salted field digests covered by the issuer signature, and the salts for the disclosed fields
+	Digests []string          `json:"digests,omitempty"`
+	Salts   map[string]string `json:"salts,omitempty"`
+
+	IssuedAt  *time.Time `json:"issued_at,omitempty"`
+	NotBefore *time.Time `json:"not_before,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+
 	Subject Signature `json:"subject"`
 	Issuer  Signature `json:"issuer"`
+
+	//JWT-VC encoding of the credential, used instead of the issuer signature
+	JWT string `json:"jwt,omitempty"`
+}
+
+type VerifiablePresentation struct {
+	Credentials []VerifiableCredential `json:"credentials"`
+
+	Nonce     string    `json:"nonce"`
+	Audience  string    `json:"audience"`
+	Timestamp time.Time `json:"timestamp"`
+
+	Holder Signature `json:"holder"`
+
+	//credentials that were sent as a JWT followed by their disclosures, by index, so they are encoded as they were sent
+	encodedCredentials []string
+}
+
+// presentation has the fields of VerifiablePresentation without its JSON methods.
+type presentation VerifiablePresentation
+
+// UnmarshalJSON decodes each credential from a JSON object, or from a string holding the credential's JWT
+// followed by its disclosures.
+func (vp *VerifiablePresentation) UnmarshalJSON(data []byte) error {
+	decoded := struct {
+		*presentation
+		Credentials []json.RawMessage `json:"credentials"`
+	}{
+		presentation: (*presentation)(vp),
+	}
+
+	err := json.Unmarshal(data, &decoded)
+	if err != nil {
+		return err
+	}
+
+	vp.Credentials = make([]VerifiableCredential, len(decoded.Credentials))
+	vp.encodedCredentials = nil
+
+	for i, raw := range decoded.Credentials {
+		var encoded string
+		if json.Unmarshal(raw, &encoded) != nil {
+			err = json.Unmarshal(raw, &vp.Credentials[i])
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		cred, err := ParseJWTCredential(encoded)
+		if err != nil {
+			return ChainError("error parsing JWT credential", err)
+		}
+		vp.Credentials[i] = *cred
+
+		if vp.encodedCredentials == nil {
+			vp.encodedCredentials = make([]string, len(decoded.Credentials))
+		}
+		vp.encodedCredentials[i] = encoded
+	}
+
+	return nil
+}
+
+// MarshalJSON encodes the credentials that were decoded from a JWT as they were sent, so the holder signature covers them,
+// and every other credential as a JSON object.
+func (vp VerifiablePresentation) MarshalJSON() ([]byte, error) {
+	if vp.encodedCredentials == nil {
+		return json.Marshal(presentation(vp))
+	}
+
+	creds := make([]interface{}, len(vp.Credentials))
+	for i := range vp.Credentials {
+		creds[i] = &vp.Credentials[i]
+		if i < len(vp.encodedCredentials) && vp.encodedCredentials[i] != "" {
+			creds[i] = vp.encodedCredentials[i]
+		}
+	}
+
+	return json.Marshal(struct {
+		presentation
+		Credentials []interface{} `json:"credentials"`
+	}{
+		presentation: presentation(vp),
+		Credentials:  creds,
+	})
 }
 
 func ChainError(message string, err error) error {
-	return errors.New(message + "\n\t" + err.Error())
+	return fmt.Errorf("%s\n\t%w", message, err)
 }
 
 func LogChainError(message string, err error) {
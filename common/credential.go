@@ -0,0 +1,89 @@
+package common
+
+import (
+	"errors"
+	"time"
+)
+
+var ErrCredentialExpired = errors.New("credential is expired")
+var ErrCredentialNotYetValid = errors.New("credential is not yet valid")
+
+// SetValidityPeriod sets the issued and not-before times to now, and the expiry to now plus validFor.
+// A validFor of zero means the credential never expires.
+func (c *VerifiableCredential) SetValidityPeriod(now time.Time, validFor time.Duration) {
+	now = now.UTC().Truncate(time.Second)
+
+	c.IssuedAt = &now
+	c.NotBefore = &now
+	c.ExpiresAt = nil
+
+	if validFor > 0 {
+		expiresAt := now.Add(validFor)
+		c.ExpiresAt = &expiresAt
+	}
+}
+
+// CheckValidityPeriod returns an error if the credential is expired or not yet valid at the provided time.
+func (c *VerifiableCredential) CheckValidityPeriod(now time.Time) error {
+	if c.NotBefore != nil && now.Before(*c.NotBefore) {
+		return ErrCredentialNotYetValid
+	}
+
+	if c.ExpiresAt != nil && !now.Before(*c.ExpiresAt) {
+		return ErrCredentialExpired
+	}
+
+	return nil
+}
+
+// IsSatisfiedBy returns true if the credential has the required type, was issued by an accepted issuer,
+// and contains all the required fields.
+func (r *CredentialRequirement) IsSatisfiedBy(cred *VerifiableCredential) bool {
+	if cred.CredType != r.CredType {
+		return false
+	}
+
+	accepted := false
+	for _, issuer := range r.Issuers {
+		if cred.Issuer.DID == issuer {
+			accepted = true
+			break
+		}
+	}
+	if !accepted {
+		return false
+	}
+
+	for _, field := range r.Fields {
+		if _, ok := cred.Credentials[field]; !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+// MatchRequirements returns a distinct credential satisfying each requirement, in the same order as the requirements.
+func MatchRequirements(reqs []CredentialRequirement, creds []VerifiableCredential) ([]VerifiableCredential, error) {
+	used := make([]bool, len(creds))
+	matched := make([]VerifiableCredential, 0, len(reqs))
+
+	for _, req := range reqs {
+		found := false
+
+		for i := range creds {
+			if !used[i] && req.IsSatisfiedBy(&creds[i]) {
+				used[i] = true
+				matched = append(matched, creds[i])
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return nil, errors.New("no credential satisfies requirement for " + req.CredType)
+		}
+	}
+
+	return matched, nil
+}
@@ -3,137 +3,127 @@ package common
 import (
 	"crypto"
 	"crypto/rand"
-	"crypto/rsa"
-	"crypto/sha256"
-	"crypto/x509"
 	"encoding/base64"
 	"encoding/json"
-	"encoding/pem"
 	"errors"
-	"io/ioutil"
+	"fmt"
+	"strings"
+	"time"
 )
 
-func SignStruct(keyURI string, sig *Signature, v interface{}) error {
-	key, err := loadPrivateKeyFromFile(keyURI)
-	if err != nil {
-		return ChainError("error loading private key from file", err)
-	}
+func GenerateUUID() (string, error) {
+	bytes := make([]byte, 16)
 
-	bytes, err := json.Marshal(v)
+	_, err := rand.Read(bytes)
 	if err != nil {
-		return ChainError("error marshaling json", err)
+		return "", ChainError("error generating random bytes", err)
 	}
 
-	hash := sha256.Sum256(bytes)
-	sigBytes, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hash[:])
-	if err != nil {
-		return ChainError("error signing hash", err)
-	}
+	//set version 4 and variant bits
+	bytes[6] = (bytes[6] & 0x0f) | 0x40
+	bytes[8] = (bytes[8] & 0x3f) | 0x80
 
-	sig.Signature = base64.RawStdEncoding.EncodeToString(sigBytes)
-	return nil
+	return fmt.Sprintf("%x-%x-%x-%x-%x", bytes[0:4], bytes[4:6], bytes[6:8], bytes[8:10], bytes[10:]), nil
 }
 
-func VerifyStructSignature(DID []byte, sig *string, v interface{}) error {
-	sigStr := *sig
-	*sig = ""
+// prefix of signatures made over the canonical (JCS) encoding, signatures without it are over the encoding/json output
+const SIGNATURE_VERSION_JCS = "v2."
 
-	sigBytes, err := base64.RawStdEncoding.DecodeString(sigStr)
-	if err != nil {
-		return ChainError("error decoding signature", err)
-	}
+// SigningConfig selects how a service signs with its private key.
+type SigningConfig struct {
+	//signature algorithm, empty for the default algorithm of the private key
+	Algorithm string
 
-	key, err := loadPublicKeyFromBytes(DID)
-	if err != nil {
-		return ChainError("error loading public key from DID", err)
-	}
+	//id of the private key's verification method in the DID document, recorded in signatures so verifiers can pick the key after it is rotated
+	KeyID string
+}
 
-	bytes, err := json.Marshal(v)
-	if err != nil {
-		return ChainError("error marshaling json", err)
+// NewSignature returns an unsigned signature by the DID with the configured algorithm and key id.
+func (c SigningConfig) NewSignature(DID string) Signature {
+	return Signature{
+		DID:       DID,
+		Algorithm: c.Algorithm,
+		KeyID:     c.KeyID,
 	}
+}
 
-	hash := sha256.Sum256(bytes)
-	err = rsa.VerifyPKCS1v15(key, crypto.SHA256, hash[:], sigBytes)
+// SignStruct signs v with the key at keyURI, using sig.Algorithm if set or the key's default algorithm otherwise.
+func SignStruct(keyURI string, sig *Signature, v interface{}) error {
+	signer, err := LoadSignerFromFile(keyURI, sig.Algorithm)
 	if err != nil {
-		return ChainError("error verifying signature", err)
+		return ChainError("error loading signer", err)
 	}
 
-	return nil
+	return SignStructWithSigner(signer, sig, v)
 }
 
-func VerifyDIDDocumentSignature(doc *DIDDocument, verifyDID string) error {
-	sigs := doc.Signatures
-	doc.Signatures = nil
+func SignStructWithSigner(signer Signer, sig *Signature, v interface{}) error {
+	//the algorithm is set first so it is covered by the signature
+	sig.Algorithm = signer.Algorithm()
 
-	sig, ok := sigs[verifyDID]
-	if !ok {
-		return errors.New("no signature for DID")
+	bytes, err := CanonicalizeJSON(v)
+	if err != nil {
+		return ChainError("error canonicalizing json", err)
 	}
 
-	bytes, err := LoadPublicKeyFromURI(verifyDID)
+	sigBytes, err := signer.Sign(bytes)
 	if err != nil {
-		return ChainError("error loading public key from uri", err)
+		return ChainError("error signing bytes", err)
 	}
 
-	return VerifyStructSignature(bytes, &sig, doc)
+	sig.Signature = SIGNATURE_VERSION_JCS + base64.RawStdEncoding.EncodeToString(sigBytes)
+	return nil
 }
 
-func LoadKeyFromFile(filename string) ([]byte, error) {
-	bytes, err := ioutil.ReadFile(filename)
-	if err != nil {
-		return nil, ChainError("error reading file", err)
-	}
+func VerifyStructSignature(key crypto.PublicKey, sig *Signature, v interface{}) error {
+	sigStr := sig.Signature
+	sig.Signature = ""
 
-	return bytes, nil
-}
+	//determine how the struct was encoded before signing
+	canonical := strings.HasPrefix(sigStr, SIGNATURE_VERSION_JCS)
+	sigStr = strings.TrimPrefix(sigStr, SIGNATURE_VERSION_JCS)
 
-func loadPrivateKeyFromFile(filename string) (*rsa.PrivateKey, error) {
-	//read file
-	bytes, err := LoadKeyFromFile(filename)
+	sigBytes, err := base64.RawStdEncoding.DecodeString(sigStr)
 	if err != nil {
-		return nil, ChainError("error reading private key file", err)
+		return ChainError("error decoding signature", err)
 	}
 
-	//parse PEM block
-	block, _ := pem.Decode(bytes)
-	if block == nil {
-		return nil, errors.New("error parsing PEM block")
+	var bytes []byte
+	if canonical {
+		bytes, err = CanonicalizeJSON(v)
+	} else {
+		//legacy signature
+		bytes, err = json.Marshal(v)
 	}
-
-	//parse private key
-	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
 	if err != nil {
-		return nil, ChainError("error parsing private key from PEM bytes", err)
+		return ChainError("error encoding json", err)
 	}
 
-	//verify key is RSA private key
-	rsaKey, ok := key.(*rsa.PrivateKey)
-	if !ok {
-		return nil, errors.New("key is not an RSA private key")
+	err = VerifySignature(key, sig.Algorithm, bytes, sigBytes)
+	if err != nil {
+		return ChainError("error verifying signature", err)
 	}
 
-	return rsaKey, nil
+	return nil
 }
 
-func loadPublicKeyFromBytes(bytes []byte) (*rsa.PublicKey, error) {
-	//parse PEM block
-	block, _ := pem.Decode(bytes)
-	if block == nil {
-		return nil, errors.New("error parsing PEM block")
+func VerifyDIDDocumentSignature(doc *DIDDocument, verifyDID string) error {
+	sigStr, ok := doc.Signatures[verifyDID]
+	if !ok {
+		return errors.New("no signature for DID")
 	}
 
-	//parse public cert
-	cert, err := x509.ParseCertificate(block.Bytes)
-	if err != nil {
-		return nil, ChainError("error parsing certificate", err)
+	//endorsements use the default algorithm for the endorser's key
+	sig := Signature{
+		DID:       verifyDID,
+		Signature: sigStr,
 	}
 
-	//verify key is RSA public key
-	key, ok := cert.PublicKey.(*rsa.PublicKey)
-	if !ok {
-		return nil, errors.New("key is not an RSA public key")
-	}
+	//signatures are made over the document without any signatures
+	unsigned := *doc
+	unsigned.Signatures = nil
 
-	return key, nil
+	//endorsements are not timestamped so they must verify under the endorser's current keys,
+	//an endorsement made with a retired key must be signed again
+	return VerifyDIDSignature(ASSERTION_METHOD, time.Now(), &sig, &unsigned)
 }
@@ -1,75 +1,333 @@
 package common
 
 import (
+	"crypto"
 	"errors"
-	"io"
-	"net/http"
+	"net/url"
 	"os"
 	"path"
 	"strings"
+	"time"
 )
 
+const DID_CONTEXT_V1 = "https://www.w3.org/ns/did/v1"
+const JWS_2020_CONTEXT_V1 = "https://w3id.org/security/suites/jws-2020/v1"
+const MULTIKEY_CONTEXT_V1 = "https://w3id.org/security/multikey/v1"
+
+const VERIFICATION_METHOD_JWK = "JsonWebKey2020"
+const VERIFICATION_METHOD_MULTIKEY = "Multikey"
+
+// verification relationships of a DID document
+const AUTHENTICATION = "authentication"
+const ASSERTION_METHOD = "assertionMethod"
+
+// service type linking the DID to the origin of the service controlling it
+const SERVICE_LINKED_DOMAINS = "LinkedDomains"
+
+type VerificationMethod struct {
+	ID                 string `json:"id"`
+	Type               string `json:"type"`
+	Controller         string `json:"controller"`
+	PublicKeyJwk       *JWK   `json:"publicKeyJwk,omitempty"`
+	PublicKeyMultibase string `json:"publicKeyMultibase,omitempty"`
+
+	//period the key may be used to sign in, a rotated out key is kept to verify earlier signatures
+	ValidFrom  *time.Time `json:"validFrom,omitempty"`
+	ValidUntil *time.Time `json:"validUntil,omitempty"`
+}
+
+type DIDService struct {
+	ID              string `json:"id"`
+	Type            string `json:"type"`
+	ServiceEndpoint string `json:"serviceEndpoint"`
+}
+
 type DIDDocument struct {
-	Domain     string            `json:"domain"`
-	Routes     map[string]string `json:"routes"`
+	Context            []string             `json:"@context"`
+	ID                 string               `json:"id"`
+	VerificationMethod []VerificationMethod `json:"verificationMethod,omitempty"`
+	Authentication     []string             `json:"authentication,omitempty"`
+	AssertionMethod    []string             `json:"assertionMethod,omitempty"`
+	Service            []DIDService         `json:"service,omitempty"`
+
+	//endorsements of the document by other DIDs, keyed by the endorsing DID
 	Signatures map[string]string `json:"signatures,omitempty"`
 }
 
-func getFullURI(uri string) string {
-	tokens := strings.Split(uri, ":")
-	return path.Join("..", "blockchain", tokens[2]+".json")
+// NewVerificationMethod creates a verification method for the key, embedded as a JWK or a multibase string.
+func NewVerificationMethod(id string, controller string, key crypto.PublicKey, multibase bool) (*VerificationMethod, error) {
+	vm := VerificationMethod{
+		ID:         id,
+		Controller: controller,
+	}
+
+	var err error
+	if multibase {
+		vm.Type = VERIFICATION_METHOD_MULTIKEY
+		vm.PublicKeyMultibase, err = EncodeMultibaseKey(key)
+	} else {
+		vm.Type = VERIFICATION_METHOD_JWK
+		vm.PublicKeyJwk, err = NewJWK(key)
+	}
+	if err != nil {
+		return nil, ChainError("error encoding public key", err)
+	}
+
+	return &vm, nil
+}
+
+func (vm *VerificationMethod) PublicKey() (crypto.PublicKey, error) {
+	if vm.PublicKeyJwk != nil {
+		return vm.PublicKeyJwk.PublicKey()
+	}
+	if vm.PublicKeyMultibase != "" {
+		return ParseMultibaseKey(vm.PublicKeyMultibase)
+	}
+	return nil, errors.New("verification method " + vm.ID + " has no public key")
 }
 
-func LoadDIDDocumentFromURI(uri string) (*DIDDocument, error) {
-	f, err := os.Open(getFullURI(uri))
+// GetVerificationMethod finds the verification method by its id, which may be relative to the document id.
+func (doc *DIDDocument) GetVerificationMethod(id string) (*VerificationMethod, error) {
+	if strings.HasPrefix(id, "#") {
+		id = doc.ID + id
+	}
+
+	for i := range doc.VerificationMethod {
+		vm := &doc.VerificationMethod[i]
+		if vm.ID == id || doc.ID+vm.ID == id {
+			return vm, nil
+		}
+	}
+
+	return nil, errors.New("DID doc has no verification method " + id)
+}
+
+// IsValidAt returns whether the key could be used to sign at time t. A zero time accepts any key.
+func (vm *VerificationMethod) IsValidAt(t time.Time) bool {
+	if t.IsZero() {
+		return true
+	}
+	if vm.ValidFrom != nil && t.Before(*vm.ValidFrom) {
+		return false
+	}
+	if vm.ValidUntil != nil && !t.Before(*vm.ValidUntil) {
+		return false
+	}
+	return true
+}
+
+func (doc *DIDDocument) getRelationship(relationship string) ([]string, error) {
+	switch relationship {
+	case AUTHENTICATION:
+		return doc.Authentication, nil
+	case ASSERTION_METHOD:
+		return doc.AssertionMethod, nil
+	default:
+		return nil, errors.New("unknown verification relationship " + relationship)
+	}
+}
+
+// GetVerificationMethods returns the methods of the relationship that were valid at time t.
+// If keyID is set, only that method is returned.
+func (doc *DIDDocument) GetVerificationMethods(relationship string, keyID string, t time.Time) ([]*VerificationMethod, error) {
+	refs, err := doc.getRelationship(relationship)
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.HasPrefix(keyID, "#") {
+		keyID = doc.ID + keyID
+	}
+
+	vms := []*VerificationMethod{}
+	for _, ref := range refs {
+		vm, err := doc.GetVerificationMethod(ref)
+		if err != nil {
+			return nil, err
+		}
+
+		if keyID != "" && vm.ID != keyID && doc.ID+vm.ID != keyID {
+			continue
+		}
+		if vm.IsValidAt(t) {
+			vms = append(vms, vm)
+		}
+	}
+
+	if len(vms) == 0 {
+		if keyID != "" {
+			return nil, errors.New("DID doc has no " + relationship + " method " + keyID + " valid at " + t.Format(time.RFC3339))
+		}
+		return nil, errors.New("DID doc has no " + relationship + " method valid at " + t.Format(time.RFC3339))
+	}
+
+	return vms, nil
+}
+
+// GetDomain returns the host of the document's linked domain, or an empty string if it has none.
+func (doc *DIDDocument) GetDomain() string {
+	for _, service := range doc.Service {
+		if service.Type != SERVICE_LINKED_DOMAINS {
+			continue
+		}
+
+		u, err := url.Parse(service.ServiceEndpoint)
+		if err == nil && u.Host != "" {
+			return u.Host
+		}
+	}
+	return ""
+}
+
+// BlockchainDir is the directory backing did:example, a local replacement for a blockchain.
+// It defaults to "../blockchain" and can be set with the VCD_BLOCKCHAIN_DIR environment variable.
+var BlockchainDir = getDefaultBlockchainDir()
+
+func getDefaultBlockchainDir() string {
+	dir := os.Getenv("VCD_BLOCKCHAIN_DIR")
+	if dir != "" {
+		return dir
+	}
+	return path.Join("..", "blockchain")
+}
+
+// getRegistryFilename returns the file in the blockchain directory holding data for the DID.
+func getRegistryFilename(uri string, ext string) (string, error) {
+	did, err := ParseDID(uri)
+	if err != nil {
+		return "", err
+	}
+
+	//did:example documents are named by their identifier alone, so it must not be able to name a file outside the directory
+	name := did.ID
+	if did.Method == "example" {
+		if strings.ContainsAny(name, "/\\") || strings.Contains(name, "..") {
+			return "", ChainError("did:example identifier contains a path separator", ErrInvalidDID)
+		}
+	} else {
+		name = did.Method + "-" + url.PathEscape(did.ID)
+	}
+
+	filename := path.Join(BlockchainDir, name+ext)
+	if path.Dir(filename) != path.Clean(BlockchainDir) {
+		return "", ChainError("DID "+uri+" does not name a file in the blockchain directory", ErrInvalidDID)
+	}
+
+	return filename, nil
+}
+
+// FileDIDResolver resolves did:example documents stored in the blockchain directory.
+type FileDIDResolver struct{}
+
+func (FileDIDResolver) Resolve(did DID) (*DIDDocument, *DIDDocumentMetadata, error) {
+	filename, err := getRegistryFilename(did.String(), ".json")
 	if err != nil {
-		return nil, ChainError("error opening DID document file", err)
+		return nil, nil, err
+	}
+
+	f, err := os.Open(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil, ErrDIDNotFound
+	}
+	if err != nil {
+		return nil, nil, ChainError("error opening DID document file", err)
 	}
 	defer f.Close()
 
 	doc := DIDDocument{}
 	err = DecodeJSON(f, &doc)
 	if err != nil {
-		return nil, ChainError("error decoding JSON", err)
+		return nil, nil, ChainError("error decoding JSON", err)
+	}
+
+	meta := DIDDocumentMetadata{}
+	info, err := f.Stat()
+	if err == nil {
+		updated := info.ModTime().UTC()
+		meta.Updated = &updated
 	}
 
-	return &doc, nil
+	return &doc, &meta, nil
 }
 
-func LoadPublicKeyFromDocument(doc *DIDDocument) ([]byte, error) {
-	keyRoute, ok := doc.Routes["key"]
-	if !ok {
-		return nil, errors.New("DID doc has no route for public key")
+func LoadDIDDocumentFromURI(uri string) (*DIDDocument, error) {
+	res, err := ResolveDID(uri)
+	if err != nil {
+		return nil, ChainError("error resolving DID", err)
 	}
-	url := "http://" + path.Join(doc.Domain, keyRoute)
 
-	res, err := http.Get(url)
+	return res.Document, nil
+}
+
+// LoadPublicKeyFromDocument returns the current key of the document's relationship.
+func LoadPublicKeyFromDocument(doc *DIDDocument, relationship string) (crypto.PublicKey, error) {
+	vms, err := doc.GetVerificationMethods(relationship, "", time.Now())
 	if err != nil {
-		return nil, ChainError("error sending DID Get request", err)
+		return nil, err
 	}
-	defer res.Body.Close()
 
-	if res.StatusCode != http.StatusOK {
-		return nil, errors.New("error getting DID from url: " + url)
+	return vms[0].PublicKey()
+}
+
+func LoadPublicKeyFromURI(uri string, relationship string) (crypto.PublicKey, error) {
+	doc, err := LoadDIDDocumentFromURI(uri)
+	if err != nil {
+		return nil, ChainError("error loading DID document", err)
 	}
 
-	bytes, err := io.ReadAll(res.Body)
+	return LoadPublicKeyFromDocument(doc, relationship)
+}
+
+// VerifyDocumentSignature verifies sig over v against the keys of the document's relationship that were valid at time t,
+// using only the key recorded in the signature if there is one.
+func VerifyDocumentSignature(doc *DIDDocument, relationship string, t time.Time, sig *Signature, v interface{}) error {
+	vms, err := doc.GetVerificationMethods(relationship, sig.KeyID, t)
 	if err != nil {
-		return nil, ChainError("error reading request body", err)
+		return err
 	}
 
-	return bytes, nil
+	//signatures without a key id may have been made by any of the keys
+	sigStr := sig.Signature
+	for _, vm := range vms {
+		key, err := vm.PublicKey()
+		if err != nil {
+			return ChainError("error loading public key", err)
+		}
+
+		sig.Signature = sigStr
+		err = VerifyStructSignature(key, sig, v)
+		if err == nil || len(vms) == 1 {
+			return err
+		}
+	}
+
+	return errors.New("signature does not match any " + relationship + " key")
 }
 
-func LoadPublicKeyFromURI(uri string) ([]byte, error) {
-	doc, err := LoadDIDDocumentFromURI(uri)
+// VerifyDIDSignature resolves the DID of the signature and verifies it, see VerifyDocumentSignature.
+func VerifyDIDSignature(relationship string, t time.Time, sig *Signature, v interface{}) error {
+	doc, err := LoadDIDDocumentFromURI(sig.DID)
 	if err != nil {
-		return nil, ChainError("error loading DID document", err)
+		return ChainError("error loading DID document", err)
 	}
 
-	return LoadPublicKeyFromDocument(doc)
+	return VerifyDocumentSignature(doc, relationship, t, sig, v)
 }
 
+// SaveDIDDocument writes the document to the blockchain directory, only did:example documents can be saved.
 func SaveDIDDocument(uri string, doc *DIDDocument) error {
-	return WriteJSONToFile(getFullURI(uri), doc)
+	did, err := ParseDID(uri)
+	if err != nil {
+		return err
+	}
+	if did.Method != "example" {
+		return errors.New("only did:example documents can be saved")
+	}
+
+	filename, err := getRegistryFilename(uri, ".json")
+	if err != nil {
+		return err
+	}
+
+	return WriteJSONToFile(filename, doc)
 }
@@ -0,0 +1,92 @@
+package common
+
+import (
+	"crypto"
+	"errors"
+	"fmt"
+	"path"
+	"testing"
+	"time"
+)
+
+func TestRegistryFilenameStaysInBlockchainDir(t *testing.T) {
+	for _, did := range []string{
+		"did:example:../../x",
+		"did:example:..",
+		"did:example:a/b",
+		`did:example:..\x`,
+	} {
+		_, err := getRegistryFilename(did, ".json")
+		if !errors.Is(err, ErrInvalidDID) {
+			t.Errorf("%s: expected ErrInvalidDID, got %v", did, err)
+		}
+	}
+
+	for _, did := range []string{
+		"did:example:e98e0ae2-5096-4de5-8096-97df8e50cf41",
+		"did:web:example.com%3A8080",
+		"did:web:..",
+	} {
+		filename, err := getRegistryFilename(did, ".json")
+		if err != nil {
+			t.Errorf("%s: %v", did, err)
+			continue
+		}
+		if path.Dir(filename) != path.Clean(BlockchainDir) {
+			t.Errorf("%s: %s is outside the blockchain directory", did, filename)
+		}
+	}
+}
+
+// TestEndorsementByRetiredKey checks an endorsement only verifies under the endorser's current keys.
+func TestEndorsementByRetiredKey(t *testing.T) {
+	BlockchainDir = t.TempDir()
+
+	endorser := "did:example:endorser"
+	retiredAt := time.Now().UTC().Add(-time.Hour).Truncate(time.Second)
+
+	keys := []crypto.Signer{}
+	doc := DIDDocument{ID: endorser}
+	for i, validUntil := range []*time.Time{&retiredAt, nil} {
+		key, err := GenerateKey("ed25519")
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys = append(keys, key)
+
+		vm, err := NewVerificationMethod(fmt.Sprintf("%s#key-%d", endorser, i+1), endorser, key.Public(), false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		vm.ValidUntil = validUntil
+		doc.VerificationMethod = append(doc.VerificationMethod, *vm)
+		doc.AssertionMethod = append(doc.AssertionMethod, vm.ID)
+	}
+	err := SaveDIDDocument(endorser, &doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, expectValid := range []bool{false, true} {
+		endorsed := DIDDocument{ID: "did:example:endorsed"}
+
+		signer, err := NewSigner(keys[i], "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		sig := Signature{}
+		err = SignStructWithSigner(signer, &sig, &endorsed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		endorsed.Signatures = map[string]string{endorser: sig.Signature}
+
+		err = VerifyDIDDocumentSignature(&endorsed, endorser)
+		if expectValid && err != nil {
+			t.Errorf("endorsement by the current key does not verify: %v", err)
+		}
+		if !expectValid && err == nil {
+			t.Error("endorsement by the retired key verifies")
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package common
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/binary"
+	"errors"
+	"math/big"
+)
+
+// multicodec prefixes of the public key types supported by did:key
+const MULTICODEC_ED25519_PUB = 0xed
+const MULTICODEC_P256_PUB = 0x1200
+const MULTICODEC_RSA_PUB = 0x1205
+
+// multibase prefix for base58btc
+const MULTIBASE_BASE58BTC = 'z'
+
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+func encodeBase58(bytes []byte) string {
+	n := new(big.Int).SetBytes(bytes)
+	radix := big.NewInt(58)
+	mod := new(big.Int)
+
+	encoded := []byte{}
+	for n.Sign() > 0 {
+		n.DivMod(n, radix, mod)
+		encoded = append(encoded, base58Alphabet[mod.Int64()])
+	}
+
+	//leading zero bytes are encoded as the first character
+	for _, b := range bytes {
+		if b != 0 {
+			break
+		}
+		encoded = append(encoded, base58Alphabet[0])
+	}
+
+	//reverse to big endian
+	for i, j := 0, len(encoded)-1; i < j; i, j = i+1, j-1 {
+		encoded[i], encoded[j] = encoded[j], encoded[i]
+	}
+	return string(encoded)
+}
+
+func decodeBase58(str string) ([]byte, error) {
+	n := new(big.Int)
+	radix := big.NewInt(58)
+
+	zeros := 0
+	for zeros < len(str) && str[zeros] == base58Alphabet[0] {
+		zeros++
+	}
+
+	for i := 0; i < len(str); i++ {
+		digit := -1
+		for j := 0; j < len(base58Alphabet); j++ {
+			if base58Alphabet[j] == str[i] {
+				digit = j
+				break
+			}
+		}
+		if digit < 0 {
+			return nil, errors.New("invalid base58 character")
+		}
+
+		n.Mul(n, radix)
+		n.Add(n, big.NewInt(int64(digit)))
+	}
+
+	return append(make([]byte, zeros), n.Bytes()...), nil
+}
+
+// EncodeMultibaseKey encodes the public key as a multicodec prefixed, base58btc multibase string.
+func EncodeMultibaseKey(key crypto.PublicKey) (string, error) {
+	var codec uint64
+	var keyBytes []byte
+
+	switch k := key.(type) {
+	case ed25519.PublicKey:
+		codec = MULTICODEC_ED25519_PUB
+		keyBytes = k
+	case *ecdsa.PublicKey:
+		if k.Curve != elliptic.P256() {
+			return "", errors.New("only the P-256 curve is supported for ECDSA keys")
+		}
+		codec = MULTICODEC_P256_PUB
+		keyBytes = elliptic.MarshalCompressed(k.Curve, k.X, k.Y)
+	case *rsa.PublicKey:
+		codec = MULTICODEC_RSA_PUB
+		keyBytes = x509.MarshalPKCS1PublicKey(k)
+	default:
+		return "", errors.New("unsupported key type")
+	}
+
+	prefix := make([]byte, binary.MaxVarintLen64)
+	prefix = prefix[:binary.PutUvarint(prefix, codec)]
+
+	return string(MULTIBASE_BASE58BTC) + encodeBase58(append(prefix, keyBytes...)), nil
+}
+
+// ParseMultibaseKey returns the public key encoded by EncodeMultibaseKey.
+func ParseMultibaseKey(str string) (crypto.PublicKey, error) {
+	if len(str) < 2 || str[0] != MULTIBASE_BASE58BTC {
+		return nil, errors.New("key is not base58btc encoded")
+	}
+
+	bytes, err := decodeBase58(str[1:])
+	if err != nil {
+		return nil, ChainError("error decoding multibase key", err)
+	}
+
+	codec, n := binary.Uvarint(bytes)
+	if n <= 0 {
+		return nil, errors.New("invalid multicodec prefix")
+	}
+	keyBytes := bytes[n:]
+
+	switch codec {
+	case MULTICODEC_ED25519_PUB:
+		if len(keyBytes) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 public key length")
+		}
+		return ed25519.PublicKey(keyBytes), nil
+	case MULTICODEC_P256_PUB:
+		x, y := elliptic.UnmarshalCompressed(elliptic.P256(), keyBytes)
+		if x == nil {
+			return nil, errors.New("invalid P-256 public key")
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}, nil
+	case MULTICODEC_RSA_PUB:
+		key, err := x509.ParsePKCS1PublicKey(keyBytes)
+		if err != nil {
+			return nil, ChainError("error parsing RSA public key", err)
+		}
+		return key, nil
+	default:
+		return nil, errors.New("unsupported multicodec key type")
+	}
+}
+
+// EncodeDIDKey returns the did:key identifier of the public key.
+func EncodeDIDKey(key crypto.PublicKey) (string, error) {
+	id, err := EncodeMultibaseKey(key)
+	if err != nil {
+		return "", err
+	}
+	return "did:key:" + id, nil
+}
+
+// KeyDIDResolver resolves did:key identifiers to a document holding the key they describe.
+type KeyDIDResolver struct{}
+
+func (KeyDIDResolver) Resolve(did DID) (*DIDDocument, *DIDDocumentMetadata, error) {
+	_, err := ParseMultibaseKey(did.ID)
+	if err != nil {
+		return nil, nil, ChainError(err.Error(), ErrInvalidDID)
+	}
+
+	//the single key is identified by its own encoding
+	vm := VerificationMethod{
+		ID:                 did.String() + "#" + did.ID,
+		Type:               VERIFICATION_METHOD_MULTIKEY,
+		Controller:         did.String(),
+		PublicKeyMultibase: did.ID,
+	}
+
+	return &DIDDocument{
+		Context:            []string{DID_CONTEXT_V1, MULTIKEY_CONTEXT_V1},
+		ID:                 did.String(),
+		VerificationMethod: []VerificationMethod{vm},
+		Authentication:     []string{vm.ID},
+		AssertionMethod:    []string{vm.ID},
+	}, &DIDDocumentMetadata{}, nil
+}
@@ -0,0 +1,80 @@
+package common
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// how long fetching a did:web document may take, and how large the document may be
+const DID_WEB_TIMEOUT = 10 * time.Second
+const DID_WEB_MAX_DOCUMENT_SIZE = 1 << 20
+
+// didWebClient fetches did:web documents, so a slow or unresponsive domain cannot hold up a verification indefinitely.
+var didWebClient = &http.Client{Timeout: DID_WEB_TIMEOUT}
+
+// WebDIDResolver resolves did:web identifiers by fetching the document from the domain.
+// Documents on localhost are fetched over plain HTTP so the demo services can host them.
+type WebDIDResolver struct{}
+
+func getDIDWebURL(id string) (string, error) {
+	tokens := strings.Split(id, ":")
+
+	host, err := url.PathUnescape(tokens[0])
+	if err != nil || host == "" || strings.Contains(host, "/") {
+		return "", ErrInvalidDID
+	}
+
+	scheme := "https"
+	hostname := strings.SplitN(host, ":", 2)[0]
+	if hostname == "localhost" || hostname == "127.0.0.1" {
+		scheme = "http"
+	}
+
+	//documents without a path are at the well known location
+	docPath := "/.well-known"
+	if len(tokens) > 1 {
+		docPath = ""
+		for _, token := range tokens[1:] {
+			segment, err := url.PathUnescape(token)
+			if err != nil || segment == "" {
+				return "", ErrInvalidDID
+			}
+			docPath += "/" + url.PathEscape(segment)
+		}
+	}
+
+	return scheme + "://" + host + docPath + "/did.json", nil
+}
+
+func (WebDIDResolver) Resolve(did DID) (*DIDDocument, *DIDDocumentMetadata, error) {
+	url, err := getDIDWebURL(did.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	res, err := didWebClient.Get(url)
+	if err != nil {
+		return nil, nil, ChainError("error sending DID document request", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotFound {
+		return nil, nil, ErrDIDNotFound
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, nil, errors.New("error getting DID document from url: " + url)
+	}
+
+	doc := DIDDocument{}
+	//documents larger than the limit are cut off and fail to decode
+	err = DecodeJSON(io.LimitReader(res.Body, DID_WEB_MAX_DOCUMENT_SIZE), &doc)
+	if err != nil {
+		return nil, nil, ChainError("error decoding JSON", err)
+	}
+
+	return &doc, &DIDDocumentMetadata{}, nil
+}
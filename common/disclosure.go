@@ -0,0 +1,123 @@
+package common
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"sort"
+)
+
+var ErrInvalidDisclosure = errors.New("disclosed field does not match issuer digests")
+
+func createFieldDigest(salt string, name string, value string) (string, error) {
+	bytes, err := json.Marshal([]string{salt, name, value})
+	if err != nil {
+		return "", ChainError("error marshaling disclosure", err)
+	}
+
+	hash := sha256.Sum256(bytes)
+	return base64.RawURLEncoding.EncodeToString(hash[:]), nil
+}
+
+// CreateDigests generates a new salt and digest for every field in the credential, replacing any existing ones.
+func (c *VerifiableCredential) CreateDigests() error {
+	c.Digests = make([]string, 0, len(c.Credentials))
+	c.Salts = make(map[string]string, len(c.Credentials))
+
+	for name, value := range c.Credentials {
+		bytes := make([]byte, 16)
+
+		_, err := rand.Read(bytes)
+		if err != nil {
+			return ChainError("error generating salt", err)
+		}
+		salt := base64.RawURLEncoding.EncodeToString(bytes)
+
+		digest, err := createFieldDigest(salt, name, value)
+		if err != nil {
+			return ChainError("error creating field digest", err)
+		}
+
+		c.Salts[name] = salt
+		c.Digests = append(c.Digests, digest)
+	}
+
+	//sort so the digest order does not reveal the field order
+	sort.Strings(c.Digests)
+	return nil
+}
+
+// Redacted returns a copy of the credential without its field values and salts,
+// which is the form covered by the issuer signature.
+// Credentials issued without digests are returned unchanged.
+func (c VerifiableCredential) Redacted() VerifiableCredential {
+	if len(c.Digests) == 0 {
+		return c
+	}
+
+	c.Credentials = nil
+	c.Salts = nil
+	return c
+}
+
+// Disclose returns a copy of the credential revealing only the provided fields.
+// Credentials issued without digests cannot be selectively disclosed and are returned with all fields.
+func (c VerifiableCredential) Disclose(fields []string) (VerifiableCredential, error) {
+	if len(c.Digests) == 0 {
+		return c, nil
+	}
+
+	credentials := make(map[string]string, len(fields))
+	salts := make(map[string]string, len(fields))
+
+	for _, field := range fields {
+		value, ok := c.Credentials[field]
+		if !ok {
+			return c, errors.New("credential has no field " + field)
+		}
+
+		salt, ok := c.Salts[field]
+		if !ok {
+			return c, errors.New("credential has no salt for field " + field)
+		}
+
+		credentials[field] = value
+		salts[field] = salt
+	}
+
+	c.Credentials = credentials
+	c.Salts = salts
+	return c, nil
+}
+
+// VerifyDisclosures checks every disclosed field matches one of the issuer signed digests.
+func (c *VerifiableCredential) VerifyDisclosures() error {
+	if len(c.Digests) == 0 {
+		return nil
+	}
+
+	digests := make(map[string]bool, len(c.Digests))
+	for _, digest := range c.Digests {
+		digests[digest] = true
+	}
+
+	for name, value := range c.Credentials {
+		salt, ok := c.Salts[name]
+		if !ok {
+			return ErrInvalidDisclosure
+		}
+
+		digest, err := createFieldDigest(salt, name, value)
+		if err != nil {
+			return ChainError("error creating field digest", err)
+		}
+
+		if !digests[digest] {
+			return ErrInvalidDisclosure
+		}
+	}
+
+	return nil
+}
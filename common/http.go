@@ -2,6 +2,8 @@ package common
 
 import (
 	"encoding/json"
+	"fmt"
+	"io"
 	"net/http"
 )
 
@@ -25,6 +27,13 @@ func SendJSONResponse(w http.ResponseWriter, status int, res interface{}) {
 	}
 }
 
+// SendJWTResponse sends a credential encoded by EncodeJWTCredential as the response body.
+func SendJWTResponse(w http.ResponseWriter, status int, encoded string) {
+	w.Header().Set("Content-Type", JWT_MEDIA_TYPE)
+	w.WriteHeader(status)
+	io.WriteString(w, encoded)
+}
+
 func SendSuccessResponse(w http.ResponseWriter) {
 	SendJSONResponse(w, http.StatusOK, SuccessResponse{
 		Success: true,
@@ -40,3 +49,31 @@ func SendErrorResponse(w http.ResponseWriter, status int, err string) {
 func SendInternalErrorResponse(w http.ResponseWriter) {
 	SendErrorResponse(w, http.StatusInternalServerError, "an internal error occurred")
 }
+
+// PostJSON posts the body as JSON and returns the response body,
+// or an error with the status and error message if the response is not 200 OK.
+func PostJSON(url string, body interface{}) ([]byte, error) {
+	buffer, err := EncodeJSON(body)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := http.Post(url, "application/json", buffer)
+	if err != nil {
+		return nil, ChainError("error sending request", err)
+	}
+	defer res.Body.Close()
+
+	bytes, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, ChainError("error reading response", err)
+	}
+
+	if res.StatusCode != http.StatusOK {
+		result := ErrorResponse{}
+		json.Unmarshal(bytes, &result)
+		return nil, fmt.Errorf("status %d: %s", res.StatusCode, result.Error)
+	}
+
+	return bytes, nil
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"io"
 	"os"
+	"path/filepath"
 )
 
 func DecodeJSON(r io.Reader, v interface{}) error {
@@ -39,16 +40,71 @@ func LoadJSONFromFile(uri string, v interface{}) error {
 	return DecodeJSON(f, v)
 }
 
+// WriteJSONToFile encodes v and atomically replaces the file with it.
 func WriteJSONToFile(uri string, v interface{}) error {
-	f, err := os.Create(uri)
+	buffer, err := EncodeJSON(v)
 	if err != nil {
-		return ChainError("error creating JSON file", err)
+		return err
 	}
-	defer f.Close()
 
-	err = json.NewEncoder(f).Encode(v)
+	err = WriteFileAtomic(uri, buffer.Bytes(), 0644)
+	if err != nil {
+		return ChainError("error writing JSON file", err)
+	}
+
+	return nil
+}
+
+// WriteFileAtomic writes the data to a temporary file in the same directory, syncs it to disk and renames it over the file,
+// so the file always holds either its old or its new contents, even if the process crashes mid-write.
+func WriteFileAtomic(filename string, data []byte, perm os.FileMode) error {
+	tmpName, err := WriteTempFile(filename, data, perm)
+	if err != nil {
+		return err
+	}
+
+	return CommitTempFile(tmpName, filename)
+}
+
+// WriteTempFile writes the data to a temporary file in the same directory as the file and syncs it to disk.
+// The temporary file is moved into place with CommitTempFile, or should be removed if it is not needed.
+func WriteTempFile(filename string, data []byte, perm os.FileMode) (string, error) {
+	f, err := os.CreateTemp(filepath.Dir(filename), "."+filepath.Base(filename)+".tmp-*")
 	if err != nil {
-		return ChainError("error encoding/writing JSON file", err)
+		return "", ChainError("error creating temporary file", err)
+	}
+	tmpName := f.Name()
+
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmpName, perm)
+	}
+	if err != nil {
+		os.Remove(tmpName)
+		return "", ChainError("error writing temporary file", err)
+	}
+
+	return tmpName, nil
+}
+
+// CommitTempFile renames a file written by WriteTempFile over the file, removing it if the rename fails.
+func CommitTempFile(tmpName string, filename string) error {
+	err := os.Rename(tmpName, filename)
+	if err != nil {
+		os.Remove(tmpName)
+		return ChainError("error renaming temporary file", err)
+	}
+
+	//sync the directory so the rename itself is durable, not all platforms support this so errors are ignored
+	if d, err := os.Open(filepath.Dir(filename)); err == nil {
+		d.Sync()
+		d.Close()
 	}
 
 	return nil
@@ -0,0 +1,358 @@
+package common
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+const CREDENTIAL_FORMAT_JWT = "jwt"
+
+// media type of a credential sent as its JWT, followed by its disclosures
+const JWT_MEDIA_TYPE = "application/jwt"
+
+// separates the JWT of a credential from the disclosures of its fields
+const JWT_DISCLOSURE_SEPARATOR = "~"
+
+type JWTHeader struct {
+	Algorithm string `json:"alg"`
+	Type      string `json:"typ"`
+	KeyID     string `json:"kid,omitempty"`
+}
+
+type JWTCredentialClaims struct {
+	Issuer    string `json:"iss"`
+	Subject   string `json:"sub"`
+	ID        string `json:"jti,omitempty"`
+	IssuedAt  int64  `json:"iat,omitempty"`
+	NotBefore int64  `json:"nbf,omitempty"`
+	ExpiresAt int64  `json:"exp,omitempty"`
+
+	VC JWTVCClaim `json:"vc"`
+}
+
+// JWTVCClaim is the credential as a W3C data model 1.1 document, without the properties the registered claims replace.
+// Fields that can be selectively disclosed are left out of the subject, and only their digests are included.
+type JWTVCClaim struct {
+	Context           []interface{}     `json:"@context"`
+	Type              []string          `json:"type"`
+	CredentialSubject map[string]string `json:"credentialSubject"`
+	Digests           []string          `json:"digests,omitempty"`
+}
+
+func encodeJWTSegment(v interface{}) (string, error) {
+	bytes, err := json.Marshal(v)
+	if err != nil {
+		return "", ChainError("error marshaling json", err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(bytes), nil
+}
+
+func decodeJWTSegment(segment string, v interface{}) error {
+	decoded, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return ChainError("error decoding base64", err)
+	}
+
+	return DecodeJSON(bytes.NewReader(decoded), v)
+}
+
+// jwtCredentialClaims returns the claims the credential's JWT is made of.
+func jwtCredentialClaims(cred *VerifiableCredential) *JWTCredentialClaims {
+	claims := JWTCredentialClaims{
+		Issuer:  cred.Issuer.DID,
+		Subject: cred.Subject.DID,
+		VC: JWTVCClaim{
+			Context:           []interface{}{W3C_CONTEXT_V1, map[string]string{"@vocab": W3C_VCD_VOCAB}},
+			Type:              []string{W3C_CREDENTIAL_TYPE, w3cTerm(cred.CredType)},
+			CredentialSubject: map[string]string{},
+			Digests:           cred.Digests,
+		},
+	}
+
+	if cred.ID != "" {
+		claims.ID = "urn:uuid:" + cred.ID
+	}
+	if cred.IssuedAt != nil {
+		claims.IssuedAt = cred.IssuedAt.Unix()
+	}
+	if cred.NotBefore != nil {
+		claims.NotBefore = cred.NotBefore.Unix()
+	}
+	if cred.ExpiresAt != nil {
+		claims.ExpiresAt = cred.ExpiresAt.Unix()
+	}
+
+	//the values of fields with digests are only revealed by their disclosures
+	if len(cred.Digests) == 0 {
+		for name, value := range cred.Credentials {
+			claims.VC.CredentialSubject[w3cTerm(name)] = value
+		}
+	}
+
+	return &claims
+}
+
+// SignJWTCredential encodes the credential as a JWS compact serialized JWT-VC signed with the issuer's key,
+// using cred.Issuer.Algorithm if set or the key's default algorithm otherwise.
+func SignJWTCredential(keyURI string, cred *VerifiableCredential) (string, error) {
+	signer, err := LoadSignerFromFile(keyURI, cred.Issuer.Algorithm)
+	if err != nil {
+		return "", ChainError("error loading signer", err)
+	}
+
+	for name := range cred.Credentials {
+		if name == "id" {
+			return "", errors.New("credential field 'id' is reserved by the data model")
+		}
+	}
+
+	header, err := encodeJWTSegment(JWTHeader{
+		Algorithm: signer.Algorithm(),
+		Type:      "JWT",
+		KeyID:     cred.Issuer.KeyID,
+	})
+	if err != nil {
+		return "", ChainError("error encoding JWT header", err)
+	}
+
+	payload, err := encodeJWTSegment(jwtCredentialClaims(cred))
+	if err != nil {
+		return "", ChainError("error encoding JWT payload", err)
+	}
+
+	signingInput := header + "." + payload
+	sigBytes, err := signer.Sign([]byte(signingInput))
+	if err != nil {
+		return "", ChainError("error signing JWT", err)
+	}
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(sigBytes), nil
+}
+
+// VerifyJWTCredential verifies the credential's JWT was signed by the key named by the JWT's kid header,
+// or any key in the issuer's document valid at the JWT's iat claim, and that the credential matches the claims in the JWT.
+func VerifyJWTCredential(cred *VerifiableCredential, doc *DIDDocument) error {
+	segments := strings.Split(cred.JWT, ".")
+	if len(segments) != 3 {
+		return errors.New("JWT must have three segments")
+	}
+
+	header := JWTHeader{}
+	err := decodeJWTSegment(segments[0], &header)
+	if err != nil {
+		return ChainError("error decoding JWT header", err)
+	}
+
+	if header.Algorithm == "" {
+		return errors.New("JWT header has no algorithm")
+	}
+
+	sigBytes, err := base64.RawURLEncoding.DecodeString(segments[2])
+	if err != nil {
+		return ChainError("error decoding JWT signature", err)
+	}
+
+	//the claims are only trusted once the signature verifies, which fails if the iat used to choose the key was changed
+	claims := JWTCredentialClaims{}
+	err = decodeJWTSegment(segments[1], &claims)
+	if err != nil {
+		return ChainError("error decoding JWT payload", err)
+	}
+
+	issuedAt := time.Time{}
+	if claims.IssuedAt != 0 {
+		issuedAt = time.Unix(claims.IssuedAt, 0)
+	}
+
+	vms, err := doc.GetVerificationMethods(ASSERTION_METHOD, header.KeyID, issuedAt)
+	if err != nil {
+		return ChainError("error finding issuer key", err)
+	}
+
+	for _, vm := range vms {
+		key, err := vm.PublicKey()
+		if err != nil {
+			return ChainError("error loading issuer key", err)
+		}
+
+		err = VerifySignature(key, header.Algorithm, []byte(segments[0]+"."+segments[1]), sigBytes)
+		if err == nil {
+			break
+		}
+		if vm == vms[len(vms)-1] {
+			return ChainError("error verifying JWT signature", err)
+		}
+	}
+
+	expected, err := json.Marshal(jwtCredentialClaims(cred))
+	if err != nil {
+		return ChainError("error marshaling credential claims", err)
+	}
+
+	actual, err := json.Marshal(&claims)
+	if err != nil {
+		return ChainError("error marshaling JWT claims", err)
+	}
+
+	if !bytes.Equal(expected, actual) {
+		return errors.New("JWT claims do not match credential")
+	}
+
+	return nil
+}
+
+// EncodeJWTCredential returns the credential's JWT followed by a disclosure of each of its fields with a digest,
+// each disclosure the base64url encoded JSON array of the field's salt, name and value, separated by '~'.
+// The JWT is returned as it is for a credential without digests.
+func EncodeJWTCredential(cred *VerifiableCredential) (string, error) {
+	if cred.JWT == "" {
+		return "", errors.New("credential has no JWT")
+	}
+	if len(cred.Digests) == 0 {
+		return cred.JWT, nil
+	}
+
+	names := make([]string, 0, len(cred.Credentials))
+	for name := range cred.Credentials {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	encoded := cred.JWT
+	for _, name := range names {
+		salt, ok := cred.Salts[name]
+		if !ok {
+			return "", errors.New("credential has no salt for field " + name)
+		}
+
+		disclosure, err := encodeJWTSegment([]string{salt, name, cred.Credentials[name]})
+		if err != nil {
+			return "", ChainError("error encoding disclosure", err)
+		}
+		encoded += JWT_DISCLOSURE_SEPARATOR + disclosure
+	}
+
+	return encoded, nil
+}
+
+// ParseJWTCredential decodes a credential from its JWT, optionally followed by disclosures as written by EncodeJWTCredential.
+// The JWT's signature is not verified, see VerifyJWTCredential.
+func ParseJWTCredential(encoded string) (*VerifiableCredential, error) {
+	parts := strings.Split(strings.TrimSpace(encoded), JWT_DISCLOSURE_SEPARATOR)
+
+	segments := strings.Split(parts[0], ".")
+	if len(segments) != 3 {
+		return nil, errors.New("JWT must have three segments")
+	}
+
+	header := JWTHeader{}
+	err := decodeJWTSegment(segments[0], &header)
+	if err != nil {
+		return nil, ChainError("error decoding JWT header", err)
+	}
+
+	claims := JWTCredentialClaims{}
+	err = decodeJWTSegment(segments[1], &claims)
+	if err != nil {
+		return nil, ChainError("error decoding JWT payload", err)
+	}
+
+	if len(claims.VC.Type) != 2 || claims.VC.Type[0] != W3C_CREDENTIAL_TYPE {
+		return nil, errors.New("vc type must be VerifiableCredential followed by the credential type")
+	}
+
+	credType, err := fromW3CTerm(claims.VC.Type[1])
+	if err != nil {
+		return nil, err
+	}
+
+	cred := VerifiableCredential{
+		ID:       strings.TrimPrefix(claims.ID, "urn:uuid:"),
+		CredType: credType,
+		Digests:  claims.VC.Digests,
+		Subject:  Signature{DID: claims.Subject},
+		Issuer: Signature{
+			DID:       claims.Issuer,
+			Algorithm: header.Algorithm,
+			KeyID:     header.KeyID,
+		},
+		JWT: parts[0],
+	}
+
+	cred.IssuedAt = jwtTime(claims.IssuedAt)
+	cred.NotBefore = jwtTime(claims.NotBefore)
+	cred.ExpiresAt = jwtTime(claims.ExpiresAt)
+
+	//a credential without fields keeps a nil map, as it had when it was signed
+	for term, value := range claims.VC.CredentialSubject {
+		name, err := fromW3CTerm(term)
+		if err != nil {
+			return nil, err
+		}
+
+		if cred.Credentials == nil {
+			cred.Credentials = map[string]string{}
+		}
+		cred.Credentials[name] = value
+	}
+
+	for _, part := range parts[1:] {
+		//a trailing separator leaves an empty part
+		if part == "" {
+			continue
+		}
+
+		disclosure := []string{}
+		err = decodeJWTSegment(part, &disclosure)
+		if err != nil || len(disclosure) != 3 {
+			return nil, ErrInvalidDisclosure
+		}
+
+		if cred.Credentials == nil {
+			cred.Credentials = map[string]string{}
+		}
+		if cred.Salts == nil {
+			cred.Salts = map[string]string{}
+		}
+		cred.Salts[disclosure[1]] = disclosure[0]
+		cred.Credentials[disclosure[1]] = disclosure[2]
+	}
+
+	return &cred, nil
+}
+
+func jwtTime(t int64) *time.Time {
+	if t == 0 {
+		return nil
+	}
+
+	res := time.Unix(t, 0).UTC()
+	return &res
+}
+
+// DecodeCredential decodes a credential sent as a JSON object, or as its JWT followed by its disclosures.
+func DecodeCredential(r io.Reader) (*VerifiableCredential, error) {
+	body, err := io.ReadAll(r)
+	if err != nil {
+		return nil, ChainError("error reading credential", err)
+	}
+
+	if !bytes.HasPrefix(bytes.TrimSpace(body), []byte("{")) {
+		return ParseJWTCredential(string(body))
+	}
+
+	cred := VerifiableCredential{}
+	err = json.Unmarshal(body, &cred)
+	if err != nil {
+		return nil, ChainError("error decoding credential", err)
+	}
+
+	return &cred, nil
+}
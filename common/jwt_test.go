@@ -0,0 +1,116 @@
+package common
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestJWTCredentialEncoding(t *testing.T) {
+	fields := map[string]string{"Student Number": "12345", "Name": "Alice"}
+
+	for _, tc := range []struct {
+		name    string
+		fields  map[string]string
+		digests bool
+	}{
+		{"with digests", fields, true},
+		{"without digests", fields, false},
+		{"without fields", nil, false},
+	} {
+		cred := newSignedTestCredential(t, tc.fields, tc.digests, true)
+
+		//the vc claim is a W3C credential
+		claims := map[string]interface{}{}
+		err := decodeJWTSegment(strings.Split(cred.JWT, ".")[1], &claims)
+		if err != nil {
+			t.Fatal(err)
+		}
+		vc, _ := claims["vc"].(map[string]interface{})
+		if _, ok := vc["credentialSubject"].(map[string]interface{}); !ok {
+			t.Errorf("%s: vc claim has no credentialSubject: %v", tc.name, claims["vc"])
+		}
+		if claims["jti"] != "urn:uuid:"+cred.ID {
+			t.Errorf("%s: expected jti urn:uuid:%s, got %v", tc.name, cred.ID, claims["jti"])
+		}
+
+		encoded, err := EncodeJWTCredential(cred)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !tc.digests && encoded != cred.JWT {
+			t.Errorf("%s: expected the bare JWT", tc.name)
+		}
+
+		parsed, err := ParseJWTCredential(encoded)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if !reflect.DeepEqual(parsed.Credentials, cred.Credentials) {
+			t.Errorf("%s: expected fields %v, got %v", tc.name, cred.Credentials, parsed.Credentials)
+		}
+		if parsed.ID != cred.ID || parsed.CredType != cred.CredType || parsed.IssuedAt.Unix() != cred.IssuedAt.Unix() {
+			t.Errorf("%s: parsed credential does not match", tc.name)
+		}
+
+		err = verifyTestCredential(parsed)
+		if err != nil {
+			t.Errorf("%s: parsed credential does not verify: %v", tc.name, err)
+		}
+
+		//a disclosure of a different value is rejected
+		if tc.digests {
+			changed := *cred
+			changed.Credentials = map[string]string{"Name": "Mallory"}
+			encoded, err = EncodeJWTCredential(&changed)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			parsed, err = ParseJWTCredential(encoded)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if verifyTestCredential(parsed) == nil {
+				t.Errorf("%s: changed disclosure verifies", tc.name)
+			}
+		}
+	}
+}
+
+func TestPresentationKeepsEncodedCredentials(t *testing.T) {
+	cred := newSignedTestCredential(t, map[string]string{"Name": "Alice"}, true, true)
+	encoded, err := EncodeJWTCredential(cred)
+	if err != nil {
+		t.Fatal(err)
+	}
+	object, err := json.Marshal(cred)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sent := `{"credentials":["` + encoded + `",` + string(object) + `],"nonce":"n","audience":"a","timestamp":"2021-12-15T09:00:00Z","holder":{"did":"did:example:holder"}}`
+
+	vp := VerifiablePresentation{}
+	err = json.Unmarshal([]byte(sent), &vp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vp.Credentials) != 2 || vp.Credentials[0].Credentials["Name"] != "Alice" || vp.Credentials[1].JWT != cred.JWT {
+		t.Fatalf("credentials were not decoded: %+v", vp.Credentials)
+	}
+
+	//the holder signature is over the presentation as it was sent
+	canonical, err := CanonicalizeJSON(&vp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, err := CanonicalizeJSON(json.RawMessage(sent))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(canonical) != string(expected) {
+		t.Errorf("expected\n%s\ngot\n%s", expected, canonical)
+	}
+}
@@ -0,0 +1,272 @@
+package common
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"math/big"
+)
+
+// JOSE names of the supported signature algorithms
+const ALG_RS256 = "RS256" //RSASSA-PKCS1-v1_5 with SHA-256
+const ALG_PS256 = "PS256" //RSASSA-PSS with SHA-256
+const ALG_ES256 = "ES256" //ECDSA P-256 with SHA-256
+const ALG_EDDSA = "EdDSA" //Ed25519
+
+type Signer interface {
+	Algorithm() string
+	Public() crypto.PublicKey
+	Sign(bytes []byte) ([]byte, error)
+}
+
+type keySigner struct {
+	key crypto.Signer
+	alg string
+}
+
+// DefaultAlgorithm returns the algorithm used for the key when none is specified.
+func DefaultAlgorithm(key crypto.PublicKey) (string, error) {
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		return ALG_RS256, nil
+	case *ecdsa.PublicKey:
+		if k.Curve != elliptic.P256() {
+			return "", errors.New("only the P-256 curve is supported for ECDSA keys")
+		}
+		return ALG_ES256, nil
+	case ed25519.PublicKey:
+		return ALG_EDDSA, nil
+	default:
+		return "", errors.New("unsupported key type")
+	}
+}
+
+func checkAlgorithm(key crypto.PublicKey, alg string) (string, error) {
+	defaultAlg, err := DefaultAlgorithm(key)
+	if err != nil {
+		return "", err
+	}
+
+	if alg == "" {
+		return defaultAlg, nil
+	}
+
+	//RSA keys can be used with either RSA algorithm
+	if alg == defaultAlg || (defaultAlg == ALG_RS256 && alg == ALG_PS256) {
+		return alg, nil
+	}
+
+	return "", errors.New("algorithm " + alg + " cannot be used with key")
+}
+
+// NewSigner creates a signer for the private key. If alg is empty the key's default algorithm is used.
+func NewSigner(key crypto.PrivateKey, alg string) (Signer, error) {
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("unsupported private key type")
+	}
+
+	alg, err := checkAlgorithm(signer.Public(), alg)
+	if err != nil {
+		return nil, ChainError("error checking algorithm", err)
+	}
+
+	return &keySigner{
+		key: signer,
+		alg: alg,
+	}, nil
+}
+
+func (s *keySigner) Algorithm() string {
+	return s.alg
+}
+
+func (s *keySigner) Public() crypto.PublicKey {
+	return s.key.Public()
+}
+
+func (s *keySigner) Sign(bytes []byte) ([]byte, error) {
+	if s.alg == ALG_EDDSA {
+		//Ed25519 signs the message directly
+		return s.key.Sign(rand.Reader, bytes, crypto.Hash(0))
+	}
+
+	hash := sha256.Sum256(bytes)
+
+	switch s.alg {
+	case ALG_RS256:
+		return s.key.Sign(rand.Reader, hash[:], crypto.SHA256)
+	case ALG_PS256:
+		return s.key.Sign(rand.Reader, hash[:], &rsa.PSSOptions{
+			SaltLength: rsa.PSSSaltLengthEqualsHash,
+			Hash:       crypto.SHA256,
+		})
+	case ALG_ES256:
+		r, sigS, err := ecdsa.Sign(rand.Reader, s.key.(*ecdsa.PrivateKey), hash[:])
+		if err != nil {
+			return nil, err
+		}
+
+		//use the fixed size r || s encoding from JWS rather than ASN.1
+		sig := make([]byte, 64)
+		r.FillBytes(sig[:32])
+		sigS.FillBytes(sig[32:])
+		return sig, nil
+	default:
+		return nil, errors.New("unsupported algorithm " + s.alg)
+	}
+}
+
+// VerifySignature verifies the signature of the bytes using the algorithm. If alg is empty the key's default algorithm is used.
+func VerifySignature(key crypto.PublicKey, alg string, bytes []byte, sig []byte) error {
+	alg, err := checkAlgorithm(key, alg)
+	if err != nil {
+		return ChainError("error checking algorithm", err)
+	}
+
+	hash := sha256.Sum256(bytes)
+
+	switch alg {
+	case ALG_RS256:
+		return rsa.VerifyPKCS1v15(key.(*rsa.PublicKey), crypto.SHA256, hash[:], sig)
+	case ALG_PS256:
+		return rsa.VerifyPSS(key.(*rsa.PublicKey), crypto.SHA256, hash[:], sig, &rsa.PSSOptions{
+			SaltLength: rsa.PSSSaltLengthAuto,
+		})
+	case ALG_ES256:
+		if len(sig) != 64 {
+			return errors.New("invalid ECDSA signature length")
+		}
+
+		r := new(big.Int).SetBytes(sig[:32])
+		s := new(big.Int).SetBytes(sig[32:])
+		if !ecdsa.Verify(key.(*ecdsa.PublicKey), hash[:], r, s) {
+			return errors.New("invalid ECDSA signature")
+		}
+		return nil
+	case ALG_EDDSA:
+		if !ed25519.Verify(key.(ed25519.PublicKey), bytes, sig) {
+			return errors.New("invalid Ed25519 signature")
+		}
+		return nil
+	default:
+		return errors.New("unsupported algorithm " + alg)
+	}
+}
+
+// GenerateKey creates a new private key of type rsa, ecdsa (P-256) or ed25519.
+func GenerateKey(keyType string) (crypto.Signer, error) {
+	switch keyType {
+	case "rsa":
+		return rsa.GenerateKey(rand.Reader, 2048)
+	case "ecdsa":
+		return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case "ed25519":
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		return key, err
+	default:
+		return nil, errors.New("unsupported key type " + keyType)
+	}
+}
+
+// EncodePrivateKey encodes the key as a PKCS #8 PEM block, the format read by ParseSigner.
+func EncodePrivateKey(key crypto.PrivateKey) ([]byte, error) {
+	bytes, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, ChainError("error marshalling private key", err)
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: bytes}), nil
+}
+
+func SavePrivateKeyToFile(filename string, key crypto.PrivateKey) error {
+	bytes, err := EncodePrivateKey(key)
+	if err != nil {
+		return err
+	}
+
+	err = ioutil.WriteFile(filename, bytes, 0600)
+	if err != nil {
+		return ChainError("error writing private key file", err)
+	}
+
+	return nil
+}
+
+func LoadKeyFromFile(filename string) ([]byte, error) {
+	bytes, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, ChainError("error reading file", err)
+	}
+
+	return bytes, nil
+}
+
+// LoadSignerFromFile loads a PKCS #8 PEM private key and creates a signer for it.
+func LoadSignerFromFile(filename string, alg string) (Signer, error) {
+	//read file
+	bytes, err := LoadKeyFromFile(filename)
+	if err != nil {
+		return nil, ChainError("error reading private key file", err)
+	}
+
+	return ParseSigner(bytes, alg)
+}
+
+// ParseSigner parses a PKCS #8 PEM private key and creates a signer for it.
+func ParseSigner(bytes []byte, alg string) (Signer, error) {
+	//parse PEM block
+	block, _ := pem.Decode(bytes)
+	if block == nil {
+		return nil, errors.New("error parsing PEM block")
+	}
+
+	//parse private key
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, ChainError("error parsing private key from PEM bytes", err)
+	}
+
+	return NewSigner(key, alg)
+}
+
+// ParsePublicKey parses a PEM encoded certificate or PKIX public key.
+func ParsePublicKey(bytes []byte) (crypto.PublicKey, error) {
+	//parse PEM block
+	block, _ := pem.Decode(bytes)
+	if block == nil {
+		return nil, errors.New("error parsing PEM block")
+	}
+
+	var key crypto.PublicKey
+	if block.Type == "PUBLIC KEY" {
+		var err error
+		key, err = x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, ChainError("error parsing public key", err)
+		}
+	} else {
+		//parse public cert
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, ChainError("error parsing certificate", err)
+		}
+		key = cert.PublicKey
+	}
+
+	//verify key type is supported
+	_, err := DefaultAlgorithm(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
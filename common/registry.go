@@ -0,0 +1,83 @@
+package common
+
+import (
+	"errors"
+	"time"
+)
+
+var ErrIssuerNotAccredited = errors.New("issuer is not accredited to issue this credential type")
+
+// TrustRegistry maps credential types to the issuers accredited to issue them, signed by the registry operator.
+type TrustRegistry struct {
+	Operator   Signature           `json:"operator"`
+	Updated    time.Time           `json:"updated"`
+	Accredited map[string][]string `json:"accredited"`
+}
+
+func LoadTrustRegistryFromURI(uri string) (*TrustRegistry, error) {
+	filename, err := getRegistryFilename(uri, ".registry.json")
+	if err != nil {
+		return nil, ChainError("error getting trust registry filename", err)
+	}
+
+	registry := TrustRegistry{}
+
+	err = LoadJSONFromFile(filename, &registry)
+	if err != nil {
+		return nil, ChainError("error loading trust registry file", err)
+	}
+
+	if registry.Accredited == nil {
+		registry.Accredited = map[string][]string{}
+	}
+
+	return &registry, nil
+}
+
+func SaveTrustRegistry(uri string, registry *TrustRegistry) error {
+	filename, err := getRegistryFilename(uri, ".registry.json")
+	if err != nil {
+		return ChainError("error getting trust registry filename", err)
+	}
+
+	return WriteJSONToFile(filename, registry)
+}
+
+// VerifyTrustRegistry verifies the registry was signed by the expected operator.
+func VerifyTrustRegistry(registry *TrustRegistry, operatorDID string) error {
+	if registry.Operator.DID != operatorDID {
+		return errors.New("trust registry operator does not match " + operatorDID)
+	}
+
+	signed := *registry
+	return VerifyDIDSignature(ASSERTION_METHOD, registry.Updated, &signed.Operator, &signed)
+}
+
+func (r *TrustRegistry) IsAccredited(credType string, issuer string) bool {
+	for _, accredited := range r.Accredited[credType] {
+		if accredited == issuer {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckAccreditation loads the operator's registry and returns ErrIssuerNotAccredited if the credential's issuer
+// is not accredited for its type.
+func CheckAccreditation(cred *VerifiableCredential, operatorDID string) error {
+	registry, err := LoadTrustRegistryFromURI(operatorDID)
+	if err != nil {
+		return ChainError("error loading trust registry", err)
+	}
+
+	err = VerifyTrustRegistry(registry, operatorDID)
+	if err != nil {
+		return ChainError("error verifying trust registry signature", err)
+	}
+
+	if !registry.IsAccredited(cred.CredType, cred.Issuer.DID) {
+		return ErrIssuerNotAccredited
+	}
+
+	return nil
+}
@@ -0,0 +1,133 @@
+package common
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+// errors reported in the resolution metadata, named after the DID resolution spec error codes
+var ErrInvalidDID = errors.New("invalidDid")
+var ErrMethodNotSupported = errors.New("methodNotSupported")
+var ErrDIDNotFound = errors.New("notFound")
+
+const DID_CONTENT_TYPE = "application/did+json"
+
+type DID struct {
+	Method string
+	ID     string
+}
+
+func (d DID) String() string {
+	return "did:" + d.Method + ":" + d.ID
+}
+
+// ParseDID splits a DID into its method and method specific identifier.
+func ParseDID(did string) (DID, error) {
+	tokens := strings.SplitN(did, ":", 3)
+	if len(tokens) != 3 || tokens[0] != "did" || tokens[1] == "" || tokens[2] == "" {
+		return DID{}, ErrInvalidDID
+	}
+
+	for _, c := range tokens[1] {
+		if !(c >= 'a' && c <= 'z') && !(c >= '0' && c <= '9') {
+			return DID{}, ErrInvalidDID
+		}
+	}
+
+	return DID{
+		Method: tokens[1],
+		ID:     tokens[2],
+	}, nil
+}
+
+type DIDResolutionMetadata struct {
+	ContentType string `json:"contentType,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+type DIDDocumentMetadata struct {
+	Updated *time.Time `json:"updated,omitempty"`
+}
+
+type DIDResolutionResult struct {
+	ResolutionMetadata DIDResolutionMetadata `json:"didResolutionMetadata"`
+	Document           *DIDDocument          `json:"didDocument"`
+	DocumentMetadata   DIDDocumentMetadata   `json:"didDocumentMetadata"`
+}
+
+// DIDMethodResolver is a driver resolving the DIDs of a single method.
+type DIDMethodResolver interface {
+	Resolve(did DID) (*DIDDocument, *DIDDocumentMetadata, error)
+}
+
+type DIDResolver struct {
+	methods map[string]DIDMethodResolver
+}
+
+func NewDIDResolver() *DIDResolver {
+	return &DIDResolver{
+		methods: map[string]DIDMethodResolver{},
+	}
+}
+
+func (r *DIDResolver) Register(method string, driver DIDMethodResolver) {
+	r.methods[method] = driver
+}
+
+// Resolve resolves the DID with the driver registered for its method.
+// The result is always returned, with the error code in its resolution metadata if resolution failed.
+func (r *DIDResolver) Resolve(did string) (*DIDResolutionResult, error) {
+	res := &DIDResolutionResult{}
+
+	parsed, err := ParseDID(did)
+	if err != nil {
+		res.ResolutionMetadata.Error = ErrInvalidDID.Error()
+		return res, err
+	}
+
+	driver, ok := r.methods[parsed.Method]
+	if !ok {
+		res.ResolutionMetadata.Error = ErrMethodNotSupported.Error()
+		return res, ChainError("no driver for DID method "+parsed.Method, ErrMethodNotSupported)
+	}
+
+	doc, meta, err := driver.Resolve(parsed)
+	if err != nil {
+		//report the spec error code if the driver used one
+		res.ResolutionMetadata.Error = ErrDIDNotFound.Error()
+		if errors.Is(err, ErrInvalidDID) {
+			res.ResolutionMetadata.Error = ErrInvalidDID.Error()
+		}
+		return res, ChainError("error resolving "+did, err)
+	}
+
+	//a document describing another DID cannot be used for this one
+	if doc.ID != parsed.String() {
+		res.ResolutionMetadata.Error = ErrDIDNotFound.Error()
+		return res, ChainError("DID document id "+doc.ID+" does not match "+did, ErrDIDNotFound)
+	}
+
+	res.ResolutionMetadata.ContentType = DID_CONTENT_TYPE
+	res.Document = doc
+	if meta != nil {
+		res.DocumentMetadata = *meta
+	}
+
+	return res, nil
+}
+
+// DefaultDIDResolver resolves the DID methods supported by the services and wallet.
+var DefaultDIDResolver = newDefaultDIDResolver()
+
+func newDefaultDIDResolver() *DIDResolver {
+	r := NewDIDResolver()
+	r.Register("example", FileDIDResolver{})
+	r.Register("key", KeyDIDResolver{})
+	r.Register("web", WebDIDResolver{})
+	return r
+}
+
+func ResolveDID(did string) (*DIDResolutionResult, error) {
+	return DefaultDIDResolver.Resolve(did)
+}
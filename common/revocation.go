@@ -0,0 +1,98 @@
+package common
+
+import (
+	"errors"
+	"os"
+	"time"
+)
+
+var ErrCredentialRevoked = errors.New("credential has been revoked")
+
+type RevocationList struct {
+	Issuer  Signature            `json:"issuer"`
+	Updated time.Time            `json:"updated"`
+	Revoked map[string]time.Time `json:"revoked"`
+}
+
+func LoadRevocationListFromURI(uri string) (*RevocationList, error) {
+	filename, err := getRegistryFilename(uri, ".revocations.json")
+	if err != nil {
+		return nil, ChainError("error getting revocation list filename", err)
+	}
+
+	list := RevocationList{}
+
+	err = LoadJSONFromFile(filename, &list)
+	if errors.Is(err, os.ErrNotExist) {
+		//issuer has not published a revocation list yet
+		return &RevocationList{
+			Issuer: Signature{
+				DID: uri,
+			},
+			Revoked: map[string]time.Time{},
+		}, nil
+	}
+	if err != nil {
+		return nil, ChainError("error loading revocation list file", err)
+	}
+
+	if list.Revoked == nil {
+		list.Revoked = map[string]time.Time{}
+	}
+
+	return &list, nil
+}
+
+func SaveRevocationList(uri string, list *RevocationList) error {
+	filename, err := getRegistryFilename(uri, ".revocations.json")
+	if err != nil {
+		return ChainError("error getting revocation list filename", err)
+	}
+
+	return WriteJSONToFile(filename, list)
+}
+
+func VerifyRevocationListSignature(list *RevocationList) error {
+	if list.Issuer.Signature == "" {
+		//an unsigned list is only valid if it is empty
+		if len(list.Revoked) > 0 {
+			return errors.New("revocation list is not signed")
+		}
+		return nil
+	}
+
+	return VerifyDIDSignature(ASSERTION_METHOD, list.Updated, &list.Issuer, list)
+}
+
+func (l *RevocationList) IsRevoked(id string) bool {
+	_, ok := l.Revoked[id]
+	return ok
+}
+
+// CheckRevocationStatus loads the issuer's revocation list and returns ErrCredentialRevoked if the credential is on it.
+func CheckRevocationStatus(cred *VerifiableCredential) error {
+	if cred.ID == "" {
+		//credentials issued before revocation support cannot be revoked
+		return nil
+	}
+
+	list, err := LoadRevocationListFromURI(cred.Issuer.DID)
+	if err != nil {
+		return ChainError("error loading revocation list", err)
+	}
+
+	if list.Issuer.DID != cred.Issuer.DID {
+		return errors.New("revocation list issuer does not match credential issuer")
+	}
+
+	err = VerifyRevocationListSignature(list)
+	if err != nil {
+		return ChainError("error verifying revocation list signature", err)
+	}
+
+	if list.IsRevoked(cred.ID) {
+		return ErrCredentialRevoked
+	}
+
+	return nil
+}
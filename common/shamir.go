@@ -0,0 +1,128 @@
+package common
+
+import (
+	"crypto/rand"
+	"errors"
+)
+
+// SecretShare is one share of a secret split with SplitSecret, the value of each byte's polynomial at X.
+type SecretShare struct {
+	X byte
+	Y []byte
+}
+
+// gfMul multiplies in GF(2^8) with the AES reducing polynomial x^8 + x^4 + x^3 + x + 1.
+func gfMul(a byte, b byte) byte {
+	var res byte
+	for b > 0 {
+		if b&1 == 1 {
+			res ^= a
+		}
+
+		carry := a & 0x80
+		a <<= 1
+		if carry != 0 {
+			a ^= 0x1b
+		}
+		b >>= 1
+	}
+
+	return res
+}
+
+// gfInv returns the multiplicative inverse of a non-zero element, a^254.
+func gfInv(a byte) byte {
+	res := byte(1)
+	for i := 0; i < 254; i++ {
+		res = gfMul(res, a)
+	}
+
+	return res
+}
+
+// SplitSecret splits the secret into n shares using Shamir's secret sharing, any threshold of which recover it.
+// Each byte of the secret is the constant term of its own random polynomial of degree threshold - 1.
+func SplitSecret(secret []byte, n int, threshold int) ([]SecretShare, error) {
+	if threshold < 2 {
+		return nil, errors.New("threshold must be at least 2")
+	}
+	if n < threshold {
+		return nil, errors.New("number of shares must be at least the threshold")
+	}
+	if n > 255 {
+		return nil, errors.New("number of shares must be at most 255")
+	}
+	if len(secret) == 0 {
+		return nil, errors.New("secret is empty")
+	}
+
+	shares := make([]SecretShare, n)
+	for i := range shares {
+		shares[i] = SecretShare{
+			X: byte(i + 1),
+			Y: make([]byte, len(secret)),
+		}
+	}
+
+	coefficients := make([]byte, threshold)
+	for i, b := range secret {
+		coefficients[0] = b
+
+		_, err := rand.Read(coefficients[1:])
+		if err != nil {
+			return nil, ChainError("error generating polynomial coefficients", err)
+		}
+
+		//evaluate the polynomial at each share's x with Horner's method
+		for j := range shares {
+			var y byte
+			for k := threshold - 1; k >= 0; k-- {
+				y = gfMul(y, shares[j].X) ^ coefficients[k]
+			}
+			shares[j].Y[i] = y
+		}
+	}
+
+	return shares, nil
+}
+
+// CombineShares recovers the secret from the shares by Lagrange interpolation at x = 0.
+// Given fewer shares than the threshold the result is unrelated to the secret, so callers must check it.
+func CombineShares(shares []SecretShare) ([]byte, error) {
+	if len(shares) < 2 {
+		return nil, errors.New("at least 2 shares are required")
+	}
+
+	size := len(shares[0].Y)
+	seen := map[byte]bool{}
+	for _, share := range shares {
+		if share.X == 0 {
+			return nil, errors.New("share has invalid x coordinate 0")
+		}
+		if seen[share.X] {
+			return nil, errors.New("duplicate share")
+		}
+		if len(share.Y) != size {
+			return nil, errors.New("shares have different lengths")
+		}
+		seen[share.X] = true
+	}
+
+	secret := make([]byte, size)
+	for j, share := range shares {
+		//lagrange basis polynomial of the share evaluated at 0, where subtraction is xor
+		basis := byte(1)
+		for m, other := range shares {
+			if m == j {
+				continue
+			}
+			basis = gfMul(basis, gfMul(other.X, gfInv(other.X^share.X)))
+		}
+
+		for i := range secret {
+			secret[i] ^= gfMul(share.Y[i], basis)
+		}
+	}
+
+	return secret, nil
+}
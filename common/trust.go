@@ -0,0 +1,83 @@
+package common
+
+import (
+	"errors"
+	"sort"
+)
+
+var ErrUntrusted = errors.New("no endorsement chain to a trust anchor")
+
+type TrustLink struct {
+	DID    string `json:"did"`
+	Domain string `json:"domain,omitempty"`
+}
+
+type trustNode struct {
+	doc   *DIDDocument
+	chain []TrustLink
+}
+
+// FindTrustChain searches the endorsements of the document for the shortest chain to one of the anchors,
+// following at most maxDepth endorsements. The chain starts with the document's DID and ends with the anchor.
+func FindTrustChain(doc *DIDDocument, anchors []string, maxDepth int) ([]TrustLink, error) {
+	isAnchor := map[string]bool{}
+	for _, anchor := range anchors {
+		isAnchor[anchor] = true
+	}
+
+	start := []TrustLink{{DID: doc.ID, Domain: doc.GetDomain()}}
+	if isAnchor[doc.ID] {
+		return start, nil
+	}
+
+	//breadth first so the shortest chain is found
+	visited := map[string]bool{doc.ID: true}
+	queue := []trustNode{{doc: doc, chain: start}}
+
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+
+		if len(node.chain) > maxDepth {
+			continue
+		}
+
+		//sort the endorsers so the chain found is deterministic
+		endorsers := []string{}
+		for endorser := range node.doc.Signatures {
+			endorsers = append(endorsers, endorser)
+		}
+		sort.Strings(endorsers)
+
+		for _, endorser := range endorsers {
+			if visited[endorser] {
+				continue
+			}
+
+			err := VerifyDIDDocumentSignature(node.doc, endorser)
+			if err != nil {
+				LogChainError("error verifying endorsement by "+endorser, err)
+				continue
+			}
+
+			endorserDoc, err := LoadDIDDocumentFromURI(endorser)
+			if err != nil {
+				LogChainError("error loading endorser DID document", err)
+				continue
+			}
+
+			visited[endorser] = true
+			chain := append(append([]TrustLink{}, node.chain...), TrustLink{
+				DID:    endorser,
+				Domain: endorserDoc.GetDomain(),
+			})
+
+			if isAnchor[endorser] {
+				return chain, nil
+			}
+			queue = append(queue, trustNode{doc: endorserDoc, chain: chain})
+		}
+	}
+
+	return nil, ErrUntrusted
+}
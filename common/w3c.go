@@ -0,0 +1,202 @@
+package common
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const W3C_CONTEXT_V1 = "https://www.w3.org/2018/credentials/v1"
+const W3C_CONTEXT_V2 = "https://www.w3.org/ns/credentials/v2"
+const W3C_CREDENTIAL_TYPE = "VerifiableCredential"
+const W3C_PROOF_TYPE = "VcdIssuerSignature2021"
+const W3C_JWT_PROOF_TYPE = "JwtProof2020"
+
+// vocabulary the credential types, subject fields and proof terms that the W3C contexts do not define expand into
+const W3C_VCD_VOCAB = "urn:vcd:"
+
+type W3CProof struct {
+	Type               string     `json:"type"`
+	Created            *time.Time `json:"created,omitempty"`
+	VerificationMethod string     `json:"verificationMethod"`
+	ProofPurpose       string     `json:"proofPurpose"`
+	ProofValue         string     `json:"proofValue,omitempty"`
+	Algorithm          string     `json:"alg,omitempty"`
+	JWT                string     `json:"jwt,omitempty"`
+
+	//selective disclosure data needed to verify the proof value
+	Digests []string          `json:"digests,omitempty"`
+	Salts   map[string]string `json:"salts,omitempty"`
+}
+
+type W3CCredential struct {
+	//the W3C context URL, followed by an embedded context setting the vocabulary
+	Context []interface{} `json:"@context"`
+	ID      string        `json:"id,omitempty"`
+	Type    []string      `json:"type"`
+	Issuer  string        `json:"issuer"`
+
+	//data model 1.1
+	IssuanceDate   *time.Time `json:"issuanceDate,omitempty"`
+	ExpirationDate *time.Time `json:"expirationDate,omitempty"`
+
+	//data model 2.0
+	ValidFrom  *time.Time `json:"validFrom,omitempty"`
+	ValidUntil *time.Time `json:"validUntil,omitempty"`
+
+	CredentialSubject map[string]string `json:"credentialSubject"`
+	Proof             W3CProof          `json:"proof"`
+}
+
+// w3cTerm escapes a credential type or field name so it expands to a valid IRI under the vocabulary.
+func w3cTerm(name string) string {
+	return url.PathEscape(name)
+}
+
+func fromW3CTerm(term string) (string, error) {
+	name, err := url.PathUnescape(term)
+	if err != nil {
+		return "", errors.New("invalid term " + term)
+	}
+
+	return name, nil
+}
+
+// ToW3CCredential converts the credential to a W3C Verifiable Credentials Data Model document.
+// Version must be 1 or 2 for data model 1.1 or 2.0.
+func ToW3CCredential(cred *VerifiableCredential, version int) (*W3CCredential, error) {
+	w3c := W3CCredential{
+		Type:   []string{W3C_CREDENTIAL_TYPE, w3cTerm(cred.CredType)},
+		Issuer: cred.Issuer.DID,
+		CredentialSubject: map[string]string{
+			"id": cred.Subject.DID,
+		},
+		Proof: W3CProof{
+			Type:               W3C_PROOF_TYPE,
+			Created:            cred.IssuedAt,
+			VerificationMethod: cred.Issuer.DID,
+			ProofPurpose:       "assertionMethod",
+			ProofValue:         cred.Issuer.Signature,
+			Algorithm:          cred.Issuer.Algorithm,
+			Digests:            cred.Digests,
+			Salts:              cred.Salts,
+		},
+	}
+
+	//reference the issuer's key when it is known
+	if cred.Issuer.KeyID != "" {
+		w3c.Proof.VerificationMethod = cred.Issuer.KeyID
+	}
+
+	vocab := map[string]string{"@vocab": W3C_VCD_VOCAB}
+
+	switch version {
+	case 1:
+		w3c.Context = []interface{}{W3C_CONTEXT_V1, vocab}
+		w3c.IssuanceDate = cred.NotBefore
+		w3c.ExpirationDate = cred.ExpiresAt
+	case 2:
+		w3c.Context = []interface{}{W3C_CONTEXT_V2, vocab}
+		w3c.ValidFrom = cred.NotBefore
+		w3c.ValidUntil = cred.ExpiresAt
+	default:
+		return nil, errors.New("unsupported data model version")
+	}
+
+	if cred.JWT != "" {
+		w3c.Proof.Type = W3C_JWT_PROOF_TYPE
+		w3c.Proof.JWT = cred.JWT
+	}
+
+	if cred.ID != "" {
+		w3c.ID = "urn:uuid:" + cred.ID
+	}
+
+	for name, value := range cred.Credentials {
+		if name == "id" {
+			return nil, errors.New("credential field 'id' is reserved by the data model")
+		}
+		w3c.CredentialSubject[w3cTerm(name)] = value
+	}
+
+	return &w3c, nil
+}
+
+// FromW3CCredential converts a W3C Verifiable Credentials Data Model 1.1 or 2.0 document back to a credential.
+// The issuer signature is preserved but not verified.
+func FromW3CCredential(w3c *W3CCredential) (*VerifiableCredential, error) {
+	if len(w3c.Context) == 0 {
+		return nil, errors.New("missing @context")
+	}
+
+	if len(w3c.Type) != 2 || w3c.Type[0] != W3C_CREDENTIAL_TYPE {
+		return nil, errors.New("type must be VerifiableCredential followed by the credential type")
+	}
+
+	if w3c.Proof.Type != W3C_PROOF_TYPE && w3c.Proof.Type != W3C_JWT_PROOF_TYPE {
+		return nil, errors.New("unsupported proof type " + w3c.Proof.Type)
+	}
+
+	credType, err := fromW3CTerm(w3c.Type[1])
+	if err != nil {
+		return nil, err
+	}
+
+	keyID := ""
+	if w3c.Proof.VerificationMethod != w3c.Issuer {
+		if !strings.HasPrefix(w3c.Proof.VerificationMethod, w3c.Issuer+"#") {
+			return nil, errors.New("proof verification method does not match issuer")
+		}
+		keyID = w3c.Proof.VerificationMethod
+	}
+
+	cred := VerifiableCredential{
+		ID:       strings.TrimPrefix(w3c.ID, "urn:uuid:"),
+		CredType: credType,
+		Digests:  w3c.Proof.Digests,
+		Salts:    w3c.Proof.Salts,
+		IssuedAt: w3c.Proof.Created,
+		Subject: Signature{
+			DID: w3c.CredentialSubject["id"],
+		},
+		Issuer: Signature{
+			DID:       w3c.Issuer,
+			Algorithm: w3c.Proof.Algorithm,
+			KeyID:     keyID,
+			Signature: w3c.Proof.ProofValue,
+		},
+		JWT: w3c.Proof.JWT,
+	}
+
+	context, _ := w3c.Context[0].(string)
+	switch context {
+	case W3C_CONTEXT_V1:
+		cred.NotBefore = w3c.IssuanceDate
+		cred.ExpiresAt = w3c.ExpirationDate
+	case W3C_CONTEXT_V2:
+		cred.NotBefore = w3c.ValidFrom
+		cred.ExpiresAt = w3c.ValidUntil
+	default:
+		return nil, errors.New("unsupported @context " + context)
+	}
+
+	//a credential without fields keeps a nil map, as it had when it was signed
+	for term, value := range w3c.CredentialSubject {
+		if term == "id" {
+			continue
+		}
+
+		name, err := fromW3CTerm(term)
+		if err != nil {
+			return nil, err
+		}
+
+		if cred.Credentials == nil {
+			cred.Credentials = map[string]string{}
+		}
+		cred.Credentials[name] = value
+	}
+
+	return &cred, nil
+}
@@ -0,0 +1,145 @@
+package common
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// newSignedTestCredential issues a credential from a did:key issuer, signed the way the issuer service signs it.
+func newSignedTestCredential(t *testing.T, fields map[string]string, digests bool, jwt bool) *VerifiableCredential {
+	t.Helper()
+
+	key, err := GenerateKey("ed25519")
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyURI := filepath.Join(t.TempDir(), "issuer.key")
+	err = SavePrivateKeyToFile(keyURI, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuer, err := EncodeDIDKey(key.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	issuedAt := time.Now().UTC()
+	cred := VerifiableCredential{
+		ID:          "6f1c9d8e-5a5e-4c57-9d0a-8f3b2a1c4d5e",
+		CredType:    "Student ID Card",
+		Credentials: fields,
+		IssuedAt:    &issuedAt,
+		NotBefore:   &issuedAt,
+		Subject:     Signature{DID: "did:example:holder"},
+		Issuer:      Signature{DID: issuer},
+	}
+
+	if digests {
+		err = cred.CreateDigests()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if jwt {
+		cred.JWT, err = SignJWTCredential(keyURI, &cred)
+	} else {
+		redacted := cred.Redacted()
+		redacted.Subject = Signature{DID: cred.Subject.DID}
+		err = SignStruct(keyURI, &redacted.Issuer, &redacted)
+		cred.Issuer = redacted.Issuer
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &cred
+}
+
+func verifyTestCredential(cred *VerifiableCredential) error {
+	doc, err := LoadDIDDocumentFromURI(cred.Issuer.DID)
+	if err != nil {
+		return err
+	}
+
+	if cred.JWT != "" {
+		err = VerifyJWTCredential(cred, doc)
+	} else {
+		signed := cred.Redacted()
+		signed.Subject = Signature{DID: signed.Subject.DID}
+		err = VerifyDocumentSignature(doc, ASSERTION_METHOD, *cred.IssuedAt, &signed.Issuer, &signed)
+	}
+	if err != nil {
+		return err
+	}
+
+	return cred.VerifyDisclosures()
+}
+
+func TestW3CCredentialRoundTrip(t *testing.T) {
+	fields := map[string]string{"Student Number": "12345", "Name": "Alice"}
+
+	for _, tc := range []struct {
+		name    string
+		fields  map[string]string
+		digests bool
+		jwt     bool
+	}{
+		{"with digests", fields, true, false},
+		{"without digests", fields, false, false},
+		{"without fields", nil, false, false},
+		{"jwt", fields, true, true},
+	} {
+		for _, version := range []int{1, 2} {
+			cred := newSignedTestCredential(t, tc.fields, tc.digests, tc.jwt)
+
+			err := verifyTestCredential(cred)
+			if err != nil {
+				t.Fatalf("%s v%d: issued credential does not verify: %v", tc.name, version, err)
+			}
+
+			w3c, err := ToW3CCredential(cred, version)
+			if err != nil {
+				t.Fatalf("%s v%d: %v", tc.name, version, err)
+			}
+
+			//types and field names expand under the vocabulary, so they must not contain spaces
+			if w3c.Type[1] != "Student%20ID%20Card" {
+				t.Errorf("%s v%d: type %s is not a valid term", tc.name, version, w3c.Type[1])
+			}
+			if tc.fields != nil && w3c.CredentialSubject["Student%20Number"] != "12345" {
+				t.Errorf("%s v%d: field names are not valid terms", tc.name, version)
+			}
+
+			bytes, err := json.Marshal(w3c)
+			if err != nil {
+				t.Fatal(err)
+			}
+			decoded := W3CCredential{}
+			err = json.Unmarshal(bytes, &decoded)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			imported, err := FromW3CCredential(&decoded)
+			if err != nil {
+				t.Fatalf("%s v%d: %v", tc.name, version, err)
+			}
+
+			if !reflect.DeepEqual(imported.Credentials, cred.Credentials) {
+				t.Errorf("%s v%d: expected fields %v, got %v", tc.name, version, cred.Credentials, imported.Credentials)
+			}
+			if imported.CredType != cred.CredType {
+				t.Errorf("%s v%d: expected type %s, got %s", tc.name, version, cred.CredType, imported.CredType)
+			}
+
+			err = verifyTestCredential(imported)
+			if err != nil {
+				t.Errorf("%s v%d: imported credential does not verify: %v", tc.name, version, err)
+			}
+		}
+	}
+}
@@ -1,8 +1,10 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"log"
+	"time"
 	"vcd/common"
 	"vcd/demo"
 	"vcd/issuer"
@@ -11,8 +13,12 @@ import (
 
 const ISSUER_DID = "did:example:d2f54564-cbf4-4574-904f-a49e3a6a2f1f"
 const VERIFIER_DID = "did:example:c6970460-f6b0-4eaa-9e96-75418fb8c4f9"
+const STUDENT_FARE_VERIFIER_DID = "did:example:7b1e4c52-3f0a-4d8e-9a61-2c5d8f3e9b47"
 const CRED_TYPE = "Bus Pass"
 
+const STUDENT_ID_ISSUER_DID = "did:example:e98e0ae2-5096-4de5-8096-97df8e50cf41" //university issuer
+const STUDENT_ID_CRED_TYPE = "Student ID Card"
+
 const port = 8086
 
 type Issuer struct{}
@@ -24,7 +30,13 @@ func (Issuer) CreatePresentationRequest() common.PresentationRequest {
 		EntityName:  "Bus Pass Creator",
 		CredType:    CRED_TYPE,
 		Description: "Create a new bus pass using your student ID card.",
-		Issuer:      "did:example:e98e0ae2-5096-4de5-8096-97df8e50cf41", //university issuer
+		Requirements: []common.CredentialRequirement{
+			{
+				CredType: STUDENT_ID_CRED_TYPE,
+				Issuers:  []string{STUDENT_ID_ISSUER_DID},
+				Fields:   []string{"First Name", "Last Name"},
+			},
+		},
 		Entity: common.Signature{
 			DID: ISSUER_DID,
 		},
@@ -40,9 +52,8 @@ func (Issuer) CreateVerifiableCredentials(cred *common.VerifiableCredential) (*c
 	busCreds := common.VerifiableCredential{
 		CredType: CRED_TYPE,
 		Credentials: map[string]string{
-			"First Name":      firstName,
-			"Last Name":       lastName,
-			"Expiration Date": "05-01-2022",
+			"First Name": firstName,
+			"Last Name":  lastName,
 		},
 		Subject: cred.Subject,
 		Issuer: common.Signature{
@@ -61,15 +72,59 @@ func (Verifier) CreatePresentationRequest() common.PresentationRequest {
 		EntityName:  "Bus Pass Check",
 		CredType:    CRED_TYPE,
 		Description: "Verifies the bus pass is valid and not expired.",
-		Issuer:      ISSUER_DID,
+		Requirements: []common.CredentialRequirement{
+			{
+				CredType: CRED_TYPE,
+				Issuers:  []string{ISSUER_DID},
+				Fields:   []string{"First Name", "Last Name"},
+			},
+		},
 		Entity: common.Signature{
 			DID: VERIFIER_DID,
 		},
 	}
 }
 
-func (Verifier) VerifyCredentials(cred *common.VerifiableCredential) error {
-	log.Println("(Verifier) Bus pass checked:", cred.Credentials["First Name"], cred.Credentials["Last Name"])
+func (Verifier) VerifyCredentials(creds []common.VerifiableCredential) error {
+	log.Println("(Verifier) Bus pass checked:", creds[0].Credentials["First Name"], creds[0].Credentials["Last Name"])
+	return nil
+}
+
+type StudentFareVerifier struct{}
+
+func (StudentFareVerifier) CreatePresentationRequest() common.PresentationRequest {
+	return common.PresentationRequest{
+		ServiceURL:  fmt.Sprintf("http://localhost:%d/verify/student", port),
+		EntityName:  "Bus Student Fare Check",
+		CredType:    CRED_TYPE,
+		Description: "Verifies the bus pass belongs to a current student for the discounted student fare.",
+		Requirements: []common.CredentialRequirement{
+			{
+				CredType: CRED_TYPE,
+				Issuers:  []string{ISSUER_DID},
+				Fields:   []string{"First Name", "Last Name"},
+			},
+			{
+				CredType: STUDENT_ID_CRED_TYPE,
+				Issuers:  []string{STUDENT_ID_ISSUER_DID},
+				Fields:   []string{"First Name", "Last Name"},
+			},
+		},
+		Entity: common.Signature{
+			DID: STUDENT_FARE_VERIFIER_DID,
+		},
+	}
+}
+
+func (StudentFareVerifier) VerifyCredentials(creds []common.VerifiableCredential) error {
+	busPass := creds[0].Credentials
+	studentID := creds[1].Credentials
+
+	if busPass["First Name"] != studentID["First Name"] || busPass["Last Name"] != studentID["Last Name"] {
+		return errors.New("bus pass and student ID card names do not match")
+	}
+
+	log.Println("(Student Fare Verifier) Student fare checked:", busPass["First Name"], busPass["Last Name"])
 	return nil
 }
 
@@ -80,11 +135,25 @@ func main() {
 			Issuer:        Issuer{},
 			DID:           ISSUER_DID,
 			PrivateKeyURI: "bus/keys/issuer.private.key",
+			SigningConfig: common.SigningConfig{KeyID: ISSUER_DID + "#key-1"},
+			ValidFor:      120 * 24 * time.Hour,
 		},
 		VerifierServices: map[string]verifier.VerifierService{
 			"check": {
 				Verifier:      Verifier{},
+				DID:           VERIFIER_DID,
 				PrivateKeyURI: "bus/keys/verifier.private.key",
+				SigningConfig: common.SigningConfig{KeyID: VERIFIER_DID + "#key-1"},
+				Nonces:        verifier.NewNonceStore(5 * time.Minute),
+				RegistryDID:   demo.TRUST_REGISTRY_DID,
+			},
+			"student": {
+				Verifier:      StudentFareVerifier{},
+				DID:           STUDENT_FARE_VERIFIER_DID,
+				PrivateKeyURI: "bus/keys/student-fare-verifier.private.key",
+				SigningConfig: common.SigningConfig{KeyID: STUDENT_FARE_VERIFIER_DID + "#key-1"},
+				Nonces:        verifier.NewNonceStore(5 * time.Minute),
+				RegistryDID:   demo.TRUST_REGISTRY_DID,
 			},
 		},
 	}
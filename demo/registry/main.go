@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"vcd/common"
+	"vcd/demo"
+	"vcd/registry"
+)
+
+const port = 8087
+
+func createGetHandler(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet {
+			common.SendErrorResponse(w, http.StatusBadRequest, "invalid request method")
+			return
+		}
+		handler(w, req)
+	}
+}
+
+func main() {
+	service := registry.RegistryService{
+		OperatorDID: demo.TRUST_REGISTRY_DID,
+	}
+
+	http.Handle("/", http.FileServer(http.Dir("./registry/public")))
+
+	http.HandleFunc("/registry", createGetHandler(service.GetRegistryHandler))
+	fmt.Printf("- http://localhost:%d/registry\n", port)
+
+	http.HandleFunc("/registry/lookup", createGetHandler(service.GetLookupHandler))
+	fmt.Printf("- http://localhost:%d/registry/lookup\n", port)
+
+	http.ListenAndServe(fmt.Sprintf(":%d", port), nil)
+}
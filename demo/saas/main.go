@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"log"
+	"time"
 	"vcd/common"
 	"vcd/demo"
 	"vcd/issuer"
@@ -59,15 +60,21 @@ func (LoginVerifier) CreatePresentationRequest() common.PresentationRequest {
 		EntityName:  "SaaS Login",
 		CredType:    CRED_TYPE,
 		Description: "Creates a new session for the provided account",
-		Issuer:      ISSUER_DID,
+		Requirements: []common.CredentialRequirement{
+			{
+				CredType: CRED_TYPE,
+				Issuers:  []string{ISSUER_DID},
+				Fields:   []string{"Username"},
+			},
+		},
 		Entity: common.Signature{
 			DID: VERIFIER_DID,
 		},
 	}
 }
 
-func (LoginVerifier) VerifyCredentials(cred *common.VerifiableCredential) error {
-	log.Println("(Verifier) New session:", cred.Credentials["Username"])
+func (LoginVerifier) VerifyCredentials(creds []common.VerifiableCredential) error {
+	log.Println("(Verifier) New session:", creds[0].Credentials["Username"])
 	return nil
 }
 
@@ -78,11 +85,17 @@ func main() {
 			Issuer:        Issuer{},
 			DID:           ISSUER_DID,
 			PrivateKeyURI: "saas/keys/issuer.private.key",
+			SigningConfig: common.SigningConfig{KeyID: ISSUER_DID + "#key-1"},
+			Format:        common.CREDENTIAL_FORMAT_JWT,
 		},
 		VerifierServices: map[string]verifier.VerifierService{
 			"login": {
 				Verifier:      LoginVerifier{},
+				DID:           VERIFIER_DID,
 				PrivateKeyURI: "saas/keys/verifier.private.key",
+				SigningConfig: common.SigningConfig{KeyID: VERIFIER_DID + "#key-1"},
+				Nonces:        verifier.NewNonceStore(5 * time.Minute),
+				RegistryDID:   demo.TRUST_REGISTRY_DID,
 			},
 		},
 	}
@@ -8,6 +8,9 @@ import (
 	"vcd/verifier"
 )
 
+// DID of the operator of the trust registry accrediting the demo issuers
+const TRUST_REGISTRY_DID = "did:example:5eeaaff2-4ac2-4b3b-b966-1276fa95158c"
+
 type DemoServer struct {
 	PublicURL        string
 	IssuerService    issuer.IssuerService
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"time"
 	"vcd/common"
 	"vcd/demo"
 	"vcd/issuer"
@@ -68,15 +69,22 @@ func (ExamVerifier) CreatePresentationRequest() common.PresentationRequest {
 		EntityName:  "University Exam Attendance Tracker",
 		CredType:    CRED_TYPE,
 		Description: "Records student information for the purpose of attendance tracking for the exam at 9:00am on December 15th, 2021 in room 101.",
-		Issuer:      ISSUER_DID,
+		Requirements: []common.CredentialRequirement{
+			{
+				CredType: CRED_TYPE,
+				Issuers:  []string{ISSUER_DID},
+				Fields:   []string{"First Name", "Last Name", "Student Number"},
+			},
+		},
 		Entity: common.Signature{
 			DID: EXAM_VERIFIER_DID,
 		},
 	}
 }
 
-func (ExamVerifier) VerifyCredentials(cred *common.VerifiableCredential) error {
-	log.Printf("(Exam Verifier) Verified: %s %s, %s %s", cred.Credentials["First Name"], cred.Credentials["Last Name"], cred.Credentials["Student Number"], cred.Credentials["Email"])
+func (ExamVerifier) VerifyCredentials(creds []common.VerifiableCredential) error {
+	cred := creds[0]
+	log.Printf("(Exam Verifier) Verified: %s %s, %s", cred.Credentials["First Name"], cred.Credentials["Last Name"], cred.Credentials["Student Number"])
 	return nil
 }
 
@@ -88,15 +96,22 @@ func (EventVerifier) CreatePresentationRequest() common.PresentationRequest {
 		EntityName:  "University Event Registrar",
 		CredType:    CRED_TYPE,
 		Description: "Registers the student for the job fair on January 15th, 2022. Upon registration, the student will receive a confirmation by email.",
-		Issuer:      ISSUER_DID,
+		Requirements: []common.CredentialRequirement{
+			{
+				CredType: CRED_TYPE,
+				Issuers:  []string{ISSUER_DID},
+				Fields:   []string{"First Name", "Last Name", "Email"},
+			},
+		},
 		Entity: common.Signature{
 			DID: EVENT_VERIFIER_DID,
 		},
 	}
 }
 
-func (EventVerifier) VerifyCredentials(cred *common.VerifiableCredential) error {
-	log.Printf("(Event Verifier) Registered: %s %s, %s %s", cred.Credentials["First Name"], cred.Credentials["Last Name"], cred.Credentials["Student Number"], cred.Credentials["Email"])
+func (EventVerifier) VerifyCredentials(creds []common.VerifiableCredential) error {
+	cred := creds[0]
+	log.Printf("(Event Verifier) Registered: %s %s, %s", cred.Credentials["First Name"], cred.Credentials["Last Name"], cred.Credentials["Email"])
 	return nil
 }
 
@@ -107,15 +122,25 @@ func main() {
 			Issuer:        Issuer{},
 			DID:           ISSUER_DID,
 			PrivateKeyURI: "university/keys/issuer.private.key",
+			SigningConfig: common.SigningConfig{KeyID: ISSUER_DID + "#key-1"},
+			ValidFor:      365 * 24 * time.Hour,
 		},
 		VerifierServices: map[string]verifier.VerifierService{
 			"exam": {
 				Verifier:      ExamVerifier{},
+				DID:           EXAM_VERIFIER_DID,
 				PrivateKeyURI: "university/keys/exam-verifier.private.key",
+				SigningConfig: common.SigningConfig{KeyID: EXAM_VERIFIER_DID + "#key-1"},
+				Nonces:        verifier.NewNonceStore(5 * time.Minute),
+				RegistryDID:   demo.TRUST_REGISTRY_DID,
 			},
 			"event": {
 				Verifier:      EventVerifier{},
+				DID:           EVENT_VERIFIER_DID,
 				PrivateKeyURI: "university/keys/event-verifier.private.key",
+				SigningConfig: common.SigningConfig{KeyID: EVENT_VERIFIER_DID + "#key-1"},
+				Nonces:        verifier.NewNonceStore(5 * time.Minute),
+				RegistryDID:   demo.TRUST_REGISTRY_DID,
 			},
 		},
 	}
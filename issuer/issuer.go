@@ -3,7 +3,9 @@ package issuer
 import (
 	"log"
 	"net/http"
+	"time"
 	"vcd/common"
+	"vcd/verifier"
 )
 
 type Issuer interface {
@@ -15,10 +17,19 @@ type IssuerService struct {
 	Issuer        Issuer
 	DID           string
 	PrivateKeyURI string
+
+	//how long issued credentials are valid for, zero means they never expire
+	ValidFor time.Duration
+
+	//encoding of issued credentials, either empty for the default signature or common.CREDENTIAL_FORMAT_JWT
+	Format string
+
+	common.SigningConfig
 }
 
 func (s IssuerService) GetIssueHandler(w http.ResponseWriter, _ *http.Request) {
 	pres := s.Issuer.CreatePresentationRequest()
+	pres.Entity = s.NewSignature(pres.Entity.DID)
 
 	err := common.SignStruct(s.PrivateKeyURI, &pres.Entity, &pres)
 	if err != nil {
@@ -40,7 +51,7 @@ func (s IssuerService) PostIssueHandler(w http.ResponseWriter, req *http.Request
 		return
 	}
 
-	err = common.VerifyStructSignature([]byte(cred.Subject.DID), &cred.Subject.Signature, cred)
+	err = common.VerifyDIDSignature(common.AUTHENTICATION, time.Now(), &cred.Subject, cred)
 	if err != nil {
 		common.LogChainError("error verifying subject signature", err)
 		common.SendErrorResponse(w, http.StatusUnauthorized, "Subject signature could not be verified.")
@@ -48,17 +59,18 @@ func (s IssuerService) PostIssueHandler(w http.ResponseWriter, req *http.Request
 	}
 
 	if cred.Issuer.DID != "" {
-		bytes, err := common.LoadPublicKeyFromURI(cred.Issuer.DID)
+		//match the requirements first so credentials from issuers that are not accepted are rejected without resolving them
+		pres := s.Issuer.CreatePresentationRequest()
+
+		_, err = verifier.MatchRequirements(pres.Requirements, []common.VerifiableCredential{*cred})
 		if err != nil {
-			common.LogChainError("error loading issuer public key", err)
-			common.SendInternalErrorResponse(w)
+			verifier.SendVerificationError(w, err)
 			return
 		}
 
-		err = common.VerifyStructSignature(bytes, &cred.Issuer.Signature, cred)
+		err = verifier.VerifyCredential(cred)
 		if err != nil {
-			common.LogChainError("error verifying issuer signature", err)
-			common.SendErrorResponse(w, http.StatusUnauthorized, "Issuer signature could not be verified.")
+			verifier.SendVerificationError(w, err)
 			return
 		}
 	}
@@ -69,16 +81,51 @@ func (s IssuerService) PostIssueHandler(w http.ResponseWriter, req *http.Request
 		return
 	}
 
-	cred.Issuer = common.Signature{
-		DID: s.DID,
+	cred.ID, err = common.GenerateUUID()
+	if err != nil {
+		common.LogChainError("error generating credential id", err)
+		common.SendInternalErrorResponse(w)
+		return
 	}
 
-	err = common.SignStruct(s.PrivateKeyURI, &cred.Issuer, cred)
+	cred.SetValidityPeriod(time.Now(), s.ValidFor)
+	cred.Issuer = s.NewSignature(s.DID)
+
+	err = cred.CreateDigests()
+	if err != nil {
+		common.LogChainError("error creating field digests", err)
+		common.SendInternalErrorResponse(w)
+		return
+	}
+
+	//sign only the digests so the holder can selectively disclose fields
+	if s.Format == common.CREDENTIAL_FORMAT_JWT {
+		cred.JWT, err = common.SignJWTCredential(s.PrivateKeyURI, cred)
+	} else {
+		//sign the redacted copy so the algorithm recorded while signing is covered, then keep its signature
+		redacted := cred.Redacted()
+		redacted.Subject = common.Signature{DID: cred.Subject.DID}
+		err = common.SignStruct(s.PrivateKeyURI, &redacted.Issuer, &redacted)
+		cred.Issuer = redacted.Issuer
+	}
 	if err != nil {
 		log.Println(err)
 		common.SendInternalErrorResponse(w)
 		return
 	}
 
+	//a JWT-VC is sent as the compact JWT, with the field values disclosed after it
+	if s.Format == common.CREDENTIAL_FORMAT_JWT {
+		encoded, err := common.EncodeJWTCredential(cred)
+		if err != nil {
+			common.LogChainError("error encoding JWT credential", err)
+			common.SendInternalErrorResponse(w)
+			return
+		}
+
+		common.SendJWTResponse(w, http.StatusOK, encoded)
+		return
+	}
+
 	common.SendJSONResponse(w, http.StatusOK, cred)
 }
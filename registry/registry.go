@@ -0,0 +1,66 @@
+package registry
+
+import (
+	"net/http"
+	"vcd/common"
+)
+
+type LookupResponse struct {
+	CredType   string   `json:"cred_type"`
+	Issuer     string   `json:"issuer,omitempty"`
+	Accredited bool     `json:"accredited"`
+	Issuers    []string `json:"issuers"`
+}
+
+// RegistryService serves the operator's signed trust registry from the blockchain directory.
+type RegistryService struct {
+	OperatorDID string
+}
+
+func (s RegistryService) loadRegistry(w http.ResponseWriter) (*common.TrustRegistry, bool) {
+	registry, err := common.LoadTrustRegistryFromURI(s.OperatorDID)
+	if err != nil {
+		common.LogChainError("error loading trust registry", err)
+		common.SendInternalErrorResponse(w)
+		return nil, false
+	}
+
+	return registry, true
+}
+
+// GetRegistryHandler responds with the full signed registry so clients can verify it themselves.
+func (s RegistryService) GetRegistryHandler(w http.ResponseWriter, _ *http.Request) {
+	registry, ok := s.loadRegistry(w)
+	if !ok {
+		return
+	}
+
+	common.SendJSONResponse(w, http.StatusOK, registry)
+}
+
+// GetLookupHandler responds with the issuers accredited for the cred_type parameter,
+// and whether the issuer parameter is one of them.
+func (s RegistryService) GetLookupHandler(w http.ResponseWriter, req *http.Request) {
+	credType := req.URL.Query().Get("cred_type")
+	if credType == "" {
+		common.SendErrorResponse(w, http.StatusBadRequest, "missing parameter 'cred_type'")
+		return
+	}
+
+	registry, ok := s.loadRegistry(w)
+	if !ok {
+		return
+	}
+
+	res := LookupResponse{
+		CredType: credType,
+		Issuer:   req.URL.Query().Get("issuer"),
+		Issuers:  registry.Accredited[credType],
+	}
+	if res.Issuers == nil {
+		res.Issuers = []string{}
+	}
+	res.Accredited = registry.IsAccredited(credType, res.Issuer)
+
+	common.SendJSONResponse(w, http.StatusOK, &res)
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"log"
+	"os"
+	"time"
+	"vcd/common"
+)
+
+func Run(keyURI string, did string, credType string, issuer string, remove bool) error {
+	registry, err := common.LoadTrustRegistryFromURI(did)
+	if errors.Is(err, os.ErrNotExist) {
+		//first accreditation creates the registry
+		registry = &common.TrustRegistry{
+			Accredited: map[string][]string{},
+		}
+	} else if err != nil {
+		return common.ChainError("error loading trust registry", err)
+	}
+
+	issuers := []string{}
+	for _, accredited := range registry.Accredited[credType] {
+		if accredited != issuer {
+			issuers = append(issuers, accredited)
+		}
+	}
+	if !remove {
+		issuers = append(issuers, issuer)
+	}
+
+	if len(issuers) > 0 {
+		registry.Accredited[credType] = issuers
+	} else {
+		delete(registry.Accredited, credType)
+	}
+
+	registry.Updated = time.Now().UTC().Truncate(time.Second)
+	registry.Operator = common.Signature{
+		DID: did,
+	}
+
+	err = common.SignStruct(keyURI, &registry.Operator, registry)
+	if err != nil {
+		return common.ChainError("error signing trust registry", err)
+	}
+
+	err = common.SaveTrustRegistry(did, registry)
+	if err != nil {
+		return common.ChainError("error saving trust registry", err)
+	}
+
+	return nil
+}
+
+func main() {
+	keyURI := flag.String("key", "", "URI of the registry operator's private key")
+	did := flag.String("did", "", "DID of the registry operator")
+	credType := flag.String("type", "", "credential type the issuer is accredited for")
+	issuer := flag.String("issuer", "", "DID of the issuer")
+	remove := flag.Bool("remove", false, "remove the accreditation instead of adding it")
+	flag.Parse()
+
+	err := Run(*keyURI, *did, *credType, *issuer, *remove)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"vcd/common"
+)
+
+func Run(did string, certURI string, endpoint string, multibase bool, outURI string) error {
+	cert, err := common.LoadKeyFromFile(certURI)
+	if err != nil {
+		return common.ChainError("error reading certificate", err)
+	}
+
+	key, err := common.ParsePublicKey(cert)
+	if err != nil {
+		return common.ChainError("error parsing public key", err)
+	}
+
+	vm, err := common.NewVerificationMethod(did+"#key-1", did, key, multibase)
+	if err != nil {
+		return common.ChainError("error creating verification method", err)
+	}
+
+	doc := common.DIDDocument{
+		Context:            []string{common.DID_CONTEXT_V1},
+		ID:                 did,
+		VerificationMethod: []common.VerificationMethod{*vm},
+		Authentication:     []string{vm.ID},
+		AssertionMethod:    []string{vm.ID},
+	}
+
+	if multibase {
+		doc.Context = append(doc.Context, common.MULTIKEY_CONTEXT_V1)
+	} else {
+		doc.Context = append(doc.Context, common.JWS_2020_CONTEXT_V1)
+	}
+
+	if endpoint != "" {
+		doc.Service = []common.DIDService{
+			{
+				ID:              did + "#domain",
+				Type:            common.SERVICE_LINKED_DOMAINS,
+				ServiceEndpoint: endpoint,
+			},
+		}
+	}
+
+	//did:web documents are hosted by the service rather than saved to the blockchain directory
+	if outURI != "" {
+		err = common.WriteJSONToFile(outURI, &doc)
+	} else {
+		err = common.SaveDIDDocument(did, &doc)
+	}
+	if err != nil {
+		return common.ChainError("error saving DID doc", err)
+	}
+
+	return nil
+}
+
+func main() {
+	did := flag.String("did", "", "DID of the document")
+	certURI := flag.String("cert", "", "URI of the public certificate of the DID")
+	endpoint := flag.String("domain", "", "origin of the service controlling the DID, e.g. http://localhost:8084")
+	multibase := flag.Bool("multibase", false, "embed the key as a multibase string rather than a JWK")
+	outURI := flag.String("out", "", "URI to write the document to instead of the blockchain directory")
+	flag.Parse()
+
+	err := Run(*did, *certURI, *endpoint, *multibase, *outURI)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
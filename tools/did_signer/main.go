@@ -21,6 +21,9 @@ func Run(keyURI string, did string, docURI string) error {
 		return common.ChainError("error signing DID doc", err)
 	}
 
+	if sigs == nil {
+		sigs = map[string]string{}
+	}
 	sigs[did] = sig.Signature
 	doc.Signatures = sigs
 
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"vcd/common"
+)
+
+// Split splits the holder key of the wallet served at walletURL into shares, saving each to its own file in dir.
+func Split(walletURL string, n int, threshold int, dir string) error {
+	bytes, err := common.PostJSON(walletURL+"/shares", map[string]int{
+		"shares":    n,
+		"threshold": threshold,
+	})
+	if err != nil {
+		return common.ChainError("error splitting key", err)
+	}
+
+	shares := []json.RawMessage{}
+	err = json.Unmarshal(bytes, &shares)
+	if err != nil {
+		return common.ChainError("error decoding shares", err)
+	}
+
+	err = os.MkdirAll(dir, 0700)
+	if err != nil {
+		return common.ChainError("error creating share directory", err)
+	}
+
+	for i, share := range shares {
+		filename := filepath.Join(dir, fmt.Sprintf("share-%d.json", i+1))
+
+		err = common.WriteFileAtomic(filename, share, 0600)
+		if err != nil {
+			return common.ChainError("error writing share file", err)
+		}
+		fmt.Println(filename)
+	}
+
+	return nil
+}
+
+// Recover restores the holder key of the wallet served at walletURL from the share files.
+func Recover(walletURL string, filenames []string) error {
+	shares := []json.RawMessage{}
+	for _, filename := range filenames {
+		share, err := os.ReadFile(filename)
+		if err != nil {
+			return common.ChainError("error reading share file", err)
+		}
+		shares = append(shares, share)
+	}
+
+	bytes, err := common.PostJSON(walletURL+"/recover", map[string]interface{}{
+		"shares": shares,
+	})
+	if err != nil {
+		return common.ChainError("error recovering key", err)
+	}
+
+	fmt.Println(string(bytes))
+	return nil
+}
+
+func main() {
+	walletURL := flag.String("wallet", "http://localhost:8082", "URL of the user server")
+	split := flag.Bool("split", false, "split the holder key into shares")
+	n := flag.Int("n", 5, "number of shares to split the key into")
+	threshold := flag.Int("threshold", 3, "number of shares needed to recover the key")
+	dir := flag.String("dir", "shares", "directory to save the shares to")
+	recoverKey := flag.Bool("recover", false, "recover the holder key from the share files given as arguments")
+	flag.Parse()
+
+	var err error
+	switch {
+	case *split && !*recoverKey:
+		err = Split(*walletURL, *n, *threshold, *dir)
+	case *recoverKey && !*split:
+		err = Recover(*walletURL, flag.Args())
+	default:
+		err = errors.New("exactly one of -split and -recover is required")
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
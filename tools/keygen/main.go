@@ -0,0 +1,69 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"time"
+	"vcd/common"
+)
+
+func Run(keyType string, keyURI string, certURI string, name string) error {
+	key, err := common.GenerateKey(keyType)
+	if err != nil {
+		return common.ChainError("error generating key", err)
+	}
+
+	//self-signed certificate holding the public key
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject: pkix.Name{
+			CommonName: name,
+		},
+		NotBefore: time.Now(),
+		NotAfter:  time.Now().AddDate(10, 0, 0),
+		KeyUsage:  x509.KeyUsageDigitalSignature,
+	}
+
+	certBytes, err := x509.CreateCertificate(rand.Reader, &template, &template, key.Public(), key)
+	if err != nil {
+		return common.ChainError("error creating certificate", err)
+	}
+
+	err = common.SavePrivateKeyToFile(keyURI, key)
+	if err != nil {
+		return common.ChainError("error saving private key", err)
+	}
+
+	err = ioutil.WriteFile(certURI, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certBytes}), 0644)
+	if err != nil {
+		return common.ChainError("error writing certificate", err)
+	}
+
+	did, err := common.EncodeDIDKey(key.Public())
+	if err != nil {
+		return common.ChainError("error encoding did:key", err)
+	}
+	fmt.Println(did)
+
+	return nil
+}
+
+func main() {
+	keyType := flag.String("type", "ed25519", "type of key to generate: rsa, ecdsa (P-256) or ed25519")
+	keyURI := flag.String("key", "", "URI to write the private key to")
+	certURI := flag.String("cert", "", "URI to write the public certificate to")
+	name := flag.String("name", "vcd", "common name of the certificate")
+	flag.Parse()
+
+	err := Run(*keyType, *keyURI, *certURI, *name)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
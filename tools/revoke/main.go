@@ -0,0 +1,55 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+	"vcd/common"
+)
+
+func Run(keyURI string, did string, credID string) error {
+	list, err := common.LoadRevocationListFromURI(did)
+	if err != nil {
+		return common.ChainError("error loading revocation list", err)
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	list.Revoked[credID] = now
+	list.Updated = now
+
+	list.Issuer = common.Signature{
+		DID: did,
+	}
+
+	err = common.SignStruct(keyURI, &list.Issuer, list)
+	if err != nil {
+		return common.ChainError("error signing revocation list", err)
+	}
+
+	err = common.SaveRevocationList(did, list)
+	if err != nil {
+		return common.ChainError("error saving revocation list", err)
+	}
+
+	return nil
+}
+
+func main() {
+	keyURI := flag.String("key", "", "URI of the issuer's private key")
+	did := flag.String("did", "", "DID of the issuer")
+	credID := flag.String("id", "", "ID of the credential to revoke")
+	flag.Parse()
+
+	if *keyURI == "" || *did == "" || *credID == "" {
+		fmt.Fprintln(flag.CommandLine.Output(), "-key, -did and -id are required")
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	err := Run(*keyURI, *did, *credID)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
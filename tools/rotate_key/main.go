@@ -0,0 +1,94 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+	"vcd/common"
+)
+
+func Run(did string, keyType string, keyURI string, keyID string, multibase bool) error {
+	doc, err := common.LoadDIDDocumentFromURI(did)
+	if err != nil {
+		return common.ChainError("error loading DID doc from URI", err)
+	}
+
+	if keyID == "" {
+		keyID = fmt.Sprintf("#key-%d", len(doc.VerificationMethod)+1)
+	}
+	if strings.HasPrefix(keyID, "#") {
+		keyID = did + keyID
+	}
+
+	_, err = doc.GetVerificationMethod(keyID)
+	if err == nil {
+		return errors.New("DID doc already has a verification method " + keyID)
+	}
+
+	key, err := common.GenerateKey(keyType)
+	if err != nil {
+		return common.ChainError("error generating key", err)
+	}
+
+	vm, err := common.NewVerificationMethod(keyID, did, key.Public(), multibase)
+	if err != nil {
+		return common.ChainError("error creating verification method", err)
+	}
+
+	//retire the current keys, they remain in the document to verify signatures made before now
+	now := time.Now().UTC().Truncate(time.Second)
+	for i := range doc.VerificationMethod {
+		if doc.VerificationMethod[i].ValidUntil == nil {
+			doc.VerificationMethod[i].ValidUntil = &now
+		}
+	}
+
+	vm.ValidFrom = &now
+	doc.VerificationMethod = append(doc.VerificationMethod, *vm)
+	doc.Authentication = append(doc.Authentication, vm.ID)
+	doc.AssertionMethod = append(doc.AssertionMethod, vm.ID)
+
+	//endorsements were made over the old document and no longer verify
+	for endorser := range doc.Signatures {
+		log.Println("endorsement by", endorser, "removed, it must sign the document again")
+	}
+	doc.Signatures = nil
+
+	//save the key first so the document never references a key that was lost
+	err = common.SavePrivateKeyToFile(keyURI, key)
+	if err != nil {
+		return common.ChainError("error saving private key", err)
+	}
+
+	err = common.SaveDIDDocument(did, doc)
+	if err != nil {
+		return common.ChainError("error saving DID doc", err)
+	}
+
+	fmt.Println(keyID)
+	return nil
+}
+
+func main() {
+	did := flag.String("did", "", "DID whose key is rotated")
+	keyType := flag.String("type", "ed25519", "type of the new key: rsa, ecdsa (P-256) or ed25519")
+	keyURI := flag.String("key", "", "URI to write the new private key to")
+	keyID := flag.String("id", "", "id of the new verification method, relative to the DID if it starts with #, defaults to #key-<n>")
+	multibase := flag.Bool("multibase", false, "embed the key as a multibase string rather than a JWK")
+	flag.Parse()
+
+	if *did == "" || *keyURI == "" {
+		fmt.Fprintln(flag.CommandLine.Output(), "-did and -key are required")
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	err := Run(*did, *keyType, *keyURI, *keyID, *multibase)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"vcd/common"
+)
+
+// Backup saves an encrypted backup of the wallet served at walletURL to the file.
+func Backup(walletURL string, passphrase string, filename string) error {
+	backup, err := common.PostJSON(walletURL+"/backup", map[string]string{
+		"passphrase": passphrase,
+	})
+	if err != nil {
+		return common.ChainError("error creating backup", err)
+	}
+
+	err = common.WriteFileAtomic(filename, backup, 0600)
+	if err != nil {
+		return common.ChainError("error writing backup file", err)
+	}
+
+	return nil
+}
+
+// Restore replaces the contents of the wallet served at walletURL with the backup in the file.
+func Restore(walletURL string, passphrase string, filename string) error {
+	backup, err := os.ReadFile(filename)
+	if err != nil {
+		return common.ChainError("error reading backup file", err)
+	}
+
+	_, err = common.PostJSON(walletURL+"/restore", map[string]interface{}{
+		"passphrase": passphrase,
+		"backup":     json.RawMessage(backup),
+	})
+	if err != nil {
+		return common.ChainError("error restoring backup", err)
+	}
+
+	return nil
+}
+
+func main() {
+	walletURL := flag.String("wallet", "http://localhost:8082", "URL of the user server")
+	out := flag.String("out", "", "file to save a backup of the wallet to")
+	in := flag.String("restore", "", "backup file to restore the wallet from")
+	passphrase := flag.String("passphrase", os.Getenv("VCD_BACKUP_PASSPHRASE"), "passphrase of the backup, defaults to $VCD_BACKUP_PASSPHRASE")
+	flag.Parse()
+
+	if (*out == "") == (*in == "") {
+		log.Fatal(errors.New("exactly one of -out and -restore is required"))
+	}
+	if *passphrase == "" {
+		log.Fatal(errors.New("a passphrase is required"))
+	}
+
+	var err error
+	if *out != "" {
+		err = Backup(*walletURL, *passphrase, *out)
+	} else {
+		err = Restore(*walletURL, *passphrase, *in)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Println("done")
+}
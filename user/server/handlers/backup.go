@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"encoding/json"
+	"time"
+	"vcd/common"
+)
+
+// authenticated with every backup so a wallet file cannot be restored as a backup or the other way around
+const BACKUP_ADDITIONAL_DATA = "vcd wallet backup v1"
+
+// WalletBundle is the plaintext of a wallet backup.
+type WalletBundle struct {
+	CreatedAt   time.Time      `json:"created_at"`
+	DID         string         `json:"did"`
+	PrivateKey  string         `json:"private_key"`
+	Credentials CredentialsMap `json:"credentials"`
+	ConsentLog  ConsentLog     `json:"consent_log"`
+
+	//presentation history, only kept by the SQLite store
+	Presentations []PresentationRecord `json:"presentations,omitempty"`
+}
+
+// createBackup collects the wallet's contents and encrypts them under the passphrase.
+func createBackup(passphrase string) (*encryptedEnvelope, error) {
+	DID, err := loadHolderDID()
+	if err != nil {
+		return nil, common.ChainError("error loading holder DID", err)
+	}
+
+	privateKey, err := Store.LoadPrivateKey()
+	if err != nil {
+		return nil, common.ChainError("error loading private key", err)
+	}
+
+	creds, err := Store.ListCredentials(CredentialFilter{Now: time.Now()})
+	if err != nil {
+		return nil, common.ChainError("error loading verifiable credentials", err)
+	}
+
+	consentLog, err := Store.ListConsent()
+	if err != nil {
+		return nil, common.ChainError("error loading consent log", err)
+	}
+
+	//a broken log would be authenticated again under the key when it is restored
+	err = consentLog.check(privateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	bundle := WalletBundle{
+		CreatedAt:   time.Now().UTC(),
+		DID:         DID,
+		PrivateKey:  string(privateKey),
+		Credentials: creds,
+		ConsentLog:  *consentLog,
+	}
+
+	if history, ok := Store.(PresentationHistory); ok {
+		bundle.Presentations, err = history.ListPresentations("")
+		if err != nil {
+			return nil, common.ChainError("error loading presentation history", err)
+		}
+	}
+
+	plaintext, err := json.Marshal(&bundle)
+	if err != nil {
+		return nil, common.ChainError("error marshalling wallet bundle", err)
+	}
+
+	envelope, err := newEnvelope()
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := envelope.deriveKey(passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	err = envelope.seal(key, plaintext, []byte(BACKUP_ADDITIONAL_DATA))
+	if err != nil {
+		return nil, common.ChainError("error encrypting wallet bundle", err)
+	}
+
+	return envelope, nil
+}
+
+// openBackup decrypts the backup, returning ErrWrongPassphrase if the passphrase is wrong or the backup was modified.
+func openBackup(envelope *encryptedEnvelope, passphrase string) (*WalletBundle, error) {
+	key, err := envelope.deriveKey(passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := envelope.open(key, []byte(BACKUP_ADDITIONAL_DATA))
+	if err != nil {
+		return nil, err
+	}
+
+	bundle := WalletBundle{}
+	err = json.Unmarshal(plaintext, &bundle)
+	if err != nil {
+		return nil, common.ChainError("error unmarshalling wallet bundle", err)
+	}
+
+	if bundle.Credentials == nil {
+		bundle.Credentials = CredentialsMap{}
+	}
+	if bundle.ConsentLog.Entries == nil {
+		bundle.ConsentLog.Entries = []ConsentEntry{}
+	}
+
+	return &bundle, nil
+}
+
+// verifyBundle checks the private key belongs to the bundle's DID, that every credential is held by that DID
+// and carries a valid issuer signature, and that the consent log is authenticated by the private key.
+// Expired and revoked credentials are restored as they are.
+func verifyBundle(bundle *WalletBundle) error {
+	signer, err := common.ParseSigner([]byte(bundle.PrivateKey), "")
+	if err != nil {
+		return common.ChainError("error parsing private key", err)
+	}
+
+	err = verifyHolderKey(signer, bundle.DID)
+	if err != nil {
+		return err
+	}
+
+	for id, cred := range bundle.Credentials {
+		err = verifyHeldCredential(&cred, bundle.DID)
+		if err != nil {
+			return common.ChainError("error verifying credential "+id, err)
+		}
+	}
+
+	err = bundle.ConsentLog.check([]byte(bundle.PrivateKey))
+	if err != nil {
+		return common.ChainError("error verifying consent log", err)
+	}
+
+	return nil
+}
+
+// restoreBundle replaces the wallet's key, DID, credentials, consent log and presentation history with those in the bundle.
+func restoreBundle(bundle *WalletBundle) error {
+	err := Store.Restore(bundle)
+	if err != nil {
+		return common.ChainError("error restoring wallet contents", err)
+	}
+
+	return nil
+}
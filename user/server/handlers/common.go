@@ -2,14 +2,22 @@ package handlers
 
 import (
 	"errors"
+	"fmt"
 	"io"
 	"net/http"
+	"os"
+	"strings"
+	"time"
 	"vcd/common"
+	"vcd/verifier"
 )
 
+// plaintext wallet files, used by PlainFileStore and migrated into an EncryptedFileStore when it is created
 const VC_URI = "wallet/verifiable-credentials.json"
+const PRIVATE_KEY_URI = "wallet/private.key"
 
-type CredentialsMap map[string]common.VerifiableCredential
+// optional file holding the wallet's DID, for a wallet with a DID document in the blockchain directory
+const DID_URI = "wallet/DID.txt"
 
 func sendRequest(method string, url string, body interface{}) (io.ReadCloser, CustomError, error) {
 	var buffer io.Reader = nil
@@ -50,13 +58,90 @@ func sendRequest(method string, url string, body interface{}) (io.ReadCloser, Cu
 	return res.Body, NoError(), nil
 }
 
-func loadVerifiableCredentials() (*CredentialsMap, error) {
-	creds := CredentialsMap{}
+// loadHolderDID returns the DID the wallet holds credentials under.
+// Unless a DID is configured, this is the did:key of the wallet's private key.
+func loadHolderDID() (string, error) {
+	DID, err := Store.LoadDID()
+	if err != nil {
+		return "", common.ChainError("error loading DID", err)
+	}
+	if DID != "" {
+		return DID, nil
+	}
+
+	signer, err := Store.LoadSigner()
+	if err != nil {
+		return "", common.ChainError("error loading private key", err)
+	}
+
+	return common.EncodeDIDKey(signer.Public())
+}
+
+// readDIDFile returns the DID configured in the file, or an empty string if there is no DID file.
+func readDIDFile(uri string) (string, error) {
+	bytes, err := os.ReadFile(uri)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", common.ChainError("error reading DID file", err)
+	}
+
+	return strings.TrimSpace(string(bytes)), nil
+}
+
+// didFileUpdate configures the wallet to hold credentials under the DID when its key is replaced.
+// The DID file is only needed when this is not the did:key of the private key, otherwise it is removed.
+func didFileUpdate(uri string, DID string, privateKey []byte) (walletFileUpdate, error) {
+	signer, err := common.ParseSigner(privateKey, "")
+	if err != nil {
+		return walletFileUpdate{}, common.ChainError("error parsing private key", err)
+	}
 
-	err := common.LoadJSONFromFile(VC_URI, &creds)
+	keyDID, err := common.EncodeDIDKey(signer.Public())
 	if err != nil {
-		return nil, common.ChainError("error loading JSON file", err)
+		return walletFileUpdate{}, common.ChainError("error encoding did:key", err)
+	}
+
+	if DID == keyDID {
+		return walletFileUpdate{uri: uri}, nil
+	}
+	return walletFileUpdate{uri: uri, data: []byte(DID + "\n")}, nil
+}
+
+// holderKeyProbe is signed with a holder key to check it belongs to a DID.
+type holderKeyProbe struct {
+	Holder    common.Signature `json:"holder"`
+	Timestamp time.Time        `json:"timestamp"`
+}
+
+// verifyHolderKey checks a signature by the key verifies with the DID's authentication keys.
+func verifyHolderKey(signer common.Signer, DID string) error {
+	probe := holderKeyProbe{
+		Holder: common.Signature{
+			DID: DID,
+		},
+		Timestamp: time.Now().UTC(),
+	}
+
+	err := common.SignStructWithSigner(signer, &probe.Holder, &probe)
+	if err != nil {
+		return common.ChainError("error signing with private key", err)
+	}
+
+	err = common.VerifyDIDSignature(common.AUTHENTICATION, probe.Timestamp, &probe.Holder, &probe)
+	if err != nil {
+		return common.ChainError("private key does not belong to "+DID, err)
+	}
+
+	return nil
+}
+
+// verifyHeldCredential checks the credential is held by the DID and has a valid issuer signature, whether or not it has expired.
+func verifyHeldCredential(cred *common.VerifiableCredential, DID string) error {
+	if cred.Subject.DID != DID {
+		return fmt.Errorf("credential is not held by %s", DID)
 	}
 
-	return &creds, nil
+	return verifier.VerifyIssuerSignature(cred)
 }
@@ -0,0 +1,271 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+	"vcd/common"
+)
+
+const CONSENT_LOG_URI = "wallet/consent-log.json"
+
+// the consent log is authenticated with a key derived from the holder's private key under this label
+const CONSENT_KEY_LABEL = "vcd consent log v1"
+
+var ErrConsentLogBroken = errors.New("consent log does not verify")
+
+const (
+	OUTCOME_ACCEPTED = "accepted"
+	OUTCOME_REJECTED = "rejected"
+	OUTCOME_FAILED   = "failed"
+)
+
+// ConsentLog is the wallet's record of the presentations the user consented to, oldest first.
+// Entry hashes and the head MAC are keyed with the holder's private key, so without the key the log cannot be rewritten
+// and entries cannot be removed from its end.
+type ConsentLog struct {
+	Entries []ConsentEntry `json:"entries"`
+
+	//MAC of the number of entries and the hash of the last entry, empty for a log that has never been written
+	HeadMAC string `json:"head_mac,omitempty"`
+}
+
+// ConsentEntry records a presentation the user consented to, and what was disclosed in it.
+// Each entry includes the hash of the one before it, so changing, removing or reordering entries breaks the chain.
+type ConsentEntry struct {
+	Index       int64                 `json:"index"`
+	Timestamp   time.Time             `json:"timestamp"`
+	ServiceURL  string                `json:"service_url"`
+	VerifierDID string                `json:"verifier_did"`
+	EntityName  string                `json:"entity_name"`
+	Disclosed   []PresentedCredential `json:"disclosed"`
+	Outcome     string                `json:"outcome"`
+	Message     string                `json:"message,omitempty"`
+
+	//hash of the previous entry, empty for the first entry
+	PrevHash string `json:"prev_hash"`
+	Hash     string `json:"hash"`
+}
+
+// VerifierSummary totals the presentations made to a verifier and the fields disclosed to it.
+type VerifierSummary struct {
+	VerifierDID      string    `json:"verifier_did"`
+	EntityName       string    `json:"entity_name"`
+	Presentations    int       `json:"presentations"`
+	Accepted         int       `json:"accepted"`
+	FirstPresentedAt time.Time `json:"first_presented_at"`
+	LastPresentedAt  time.Time `json:"last_presented_at"`
+
+	//names of the fields disclosed to the verifier, by credential type
+	Fields map[string][]string `json:"fields"`
+}
+
+// newConsentEntry describes a presentation that was sent, with the outcome reported by the verifier.
+func newConsentEntry(pres *common.PresentationRequest, record *PresentationRecord, cerr CustomError) *ConsentEntry {
+	entry := ConsentEntry{
+		Timestamp:   record.PresentedAt,
+		ServiceURL:  record.ServiceURL,
+		VerifierDID: pres.Entity.DID,
+		EntityName:  pres.EntityName,
+		Disclosed:   record.Credentials,
+		Outcome:     OUTCOME_ACCEPTED,
+	}
+
+	if cerr.Type == TypeClientError {
+		entry.Outcome = OUTCOME_REJECTED
+		entry.Message = cerr.Message
+	} else if cerr.Type != TypeNoError {
+		entry.Outcome = OUTCOME_FAILED
+	}
+
+	return &entry
+}
+
+// consentKey derives the key the consent log is authenticated with from the holder's PEM encoded private key.
+func consentKey(privateKey []byte) []byte {
+	der := privateKey
+	if block, _ := pem.Decode(privateKey); block != nil {
+		der = block.Bytes
+	}
+
+	mac := hmac.New(sha256.New, []byte(CONSENT_KEY_LABEL))
+	mac.Write(der)
+	return mac.Sum(nil)
+}
+
+func consentMAC(key []byte, data []byte) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func hashConsentEntry(entry *ConsentEntry, key []byte) (string, error) {
+	unhashed := *entry
+	unhashed.Hash = ""
+
+	bytes, err := json.Marshal(&unhashed)
+	if err != nil {
+		return "", common.ChainError("error marshalling consent entry", err)
+	}
+
+	return consentMAC(key, bytes), nil
+}
+
+func consentHeadMAC(entries []ConsentEntry, key []byte) string {
+	last := ""
+	if len(entries) > 0 {
+		last = entries[len(entries)-1].Hash
+	}
+
+	return consentMAC(key, []byte(fmt.Sprintf("head:%d:%s", len(entries), last)))
+}
+
+// append sets the entry's index and hashes so it follows the last entry, adds it to the log and updates the head MAC.
+func (l *ConsentLog) append(entry *ConsentEntry, privateKey []byte) error {
+	key := consentKey(privateKey)
+
+	entry.Index = 0
+	entry.PrevHash = ""
+	if len(l.Entries) > 0 {
+		last := &l.Entries[len(l.Entries)-1]
+		entry.Index = last.Index + 1
+		entry.PrevHash = last.Hash
+	}
+
+	var err error
+	entry.Hash, err = hashConsentEntry(entry, key)
+	if err != nil {
+		return err
+	}
+
+	l.Entries = append(l.Entries, *entry)
+	l.HeadMAC = consentHeadMAC(l.Entries, key)
+
+	return nil
+}
+
+// verify returns the index of the first entry whose position or hashes do not match the entries before it,
+// the number of entries if they all match but the head MAC does not, as when entries were removed from the end,
+// or -1 if the log is intact.
+func (l *ConsentLog) verify(privateKey []byte) (int64, error) {
+	key := consentKey(privateKey)
+
+	prevHash := ""
+	for i := range l.Entries {
+		entry := &l.Entries[i]
+
+		hash, err := hashConsentEntry(entry, key)
+		if err != nil {
+			return 0, err
+		}
+
+		if entry.Index != int64(i) || entry.PrevHash != prevHash || !hmac.Equal([]byte(entry.Hash), []byte(hash)) {
+			return int64(i), nil
+		}
+		prevHash = entry.Hash
+	}
+
+	//a log that has never been written has no head
+	if len(l.Entries) == 0 && l.HeadMAC == "" {
+		return -1, nil
+	}
+
+	if !hmac.Equal([]byte(l.HeadMAC), []byte(consentHeadMAC(l.Entries, key))) {
+		return int64(len(l.Entries)), nil
+	}
+
+	return -1, nil
+}
+
+// check returns ErrConsentLogBroken if the log does not verify, so that it is not extended or copied as if it were intact.
+func (l *ConsentLog) check(privateKey []byte) error {
+	brokenAt, err := l.verify(privateKey)
+	if err != nil {
+		return err
+	}
+	if brokenAt >= 0 {
+		return fmt.Errorf("%w: entry %d does not match", ErrConsentLogBroken, brokenAt)
+	}
+
+	return nil
+}
+
+// rekey authenticates the log under the new private key instead of the old one, which is nil if it could not be loaded.
+// A log that does not verify under the old key is left as it is, as it may already be written under the new key.
+func (l *ConsentLog) rekey(oldPrivateKey []byte, newPrivateKey []byte) error {
+	if oldPrivateKey == nil || l.check(oldPrivateKey) != nil {
+		return nil
+	}
+
+	entries := l.Entries
+	*l = ConsentLog{Entries: []ConsentEntry{}}
+	for i := range entries {
+		err := l.append(&entries[i], newPrivateKey)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// summarizeConsentLog groups the entries by verifier, most recently presented to first.
+func summarizeConsentLog(entries []ConsentEntry) []VerifierSummary {
+	summaries := map[string]*VerifierSummary{}
+	fields := map[string]map[string]map[string]bool{}
+
+	for _, entry := range entries {
+		summary, ok := summaries[entry.VerifierDID]
+		if !ok {
+			summary = &VerifierSummary{
+				VerifierDID:      entry.VerifierDID,
+				FirstPresentedAt: entry.Timestamp,
+				Fields:           map[string][]string{},
+			}
+			summaries[entry.VerifierDID] = summary
+			fields[entry.VerifierDID] = map[string]map[string]bool{}
+		}
+
+		summary.EntityName = entry.EntityName
+		summary.LastPresentedAt = entry.Timestamp
+		summary.Presentations++
+		if entry.Outcome == OUTCOME_ACCEPTED {
+			summary.Accepted++
+		}
+
+		for _, cred := range entry.Disclosed {
+			disclosed, ok := fields[entry.VerifierDID][cred.CredType]
+			if !ok {
+				disclosed = map[string]bool{}
+				fields[entry.VerifierDID][cred.CredType] = disclosed
+			}
+
+			for _, field := range cred.Fields {
+				if !disclosed[field] {
+					disclosed[field] = true
+					summary.Fields[cred.CredType] = append(summary.Fields[cred.CredType], field)
+				}
+			}
+		}
+	}
+
+	res := []VerifierSummary{}
+	for _, summary := range summaries {
+		for _, names := range summary.Fields {
+			sort.Strings(names)
+		}
+		res = append(res, *summary)
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].LastPresentedAt.After(res[j].LastPresentedAt)
+	})
+
+	return res
+}
@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"errors"
+	"sync"
+	"time"
+	"vcd/common"
+	"vcd/verifier"
+)
+
+// how long the checks that resolve documents over the network are reused before a credential is checked again
+const CREDENTIAL_STATUS_TTL = 5 * time.Minute
+
+type StatusCheck struct {
+	OK      bool   `json:"ok"`
+	Message string `json:"message,omitempty"`
+}
+
+// CredentialStatus is the result of checking a wallet credential.
+// Valid is true if the issuer DID resolves, the signature verifies, and the credential is within its validity period and not revoked.
+// Trust and accreditation are reported but do not affect Valid.
+type CredentialStatus struct {
+	Valid      bool        `json:"valid"`
+	IssuerDID  StatusCheck `json:"issuer_did"`
+	Signature  StatusCheck `json:"signature"`
+	Validity   StatusCheck `json:"validity"`
+	Revocation StatusCheck `json:"revocation"`
+	Trust      StatusCheck `json:"trust"`
+
+	//endorsements linking the issuer to one of the wallet's trust anchors, empty if it is not trusted
+	TrustChain []common.TrustLink `json:"trust_chain,omitempty"`
+
+	//whether the issuer is accredited for the credential type, omitted if no trust registry is configured or it is unavailable
+	Accredited *bool `json:"accredited,omitempty"`
+
+	//when the issuer's documents were last checked
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+type cachedStatus struct {
+	status    CredentialStatus
+	proof     string
+	expiresAt time.Time
+}
+
+// checked credential statuses, keyed by credential ID
+var statusCache = struct {
+	sync.Mutex
+	statuses map[string]cachedStatus
+}{
+	statuses: map[string]cachedStatus{},
+}
+
+// credentialProof identifies the issued credential, so a cached status is not used for a different credential with the same ID.
+func credentialProof(cred *common.VerifiableCredential) string {
+	return cred.JWT + cred.Issuer.Signature
+}
+
+// getCredentialStatus returns the status of the credential, reusing checks made within CREDENTIAL_STATUS_TTL unless refresh is set.
+// The validity period is always checked against the current time.
+func getCredentialStatus(id string, cred *common.VerifiableCredential, refresh bool) (*CredentialStatus, error) {
+	now := time.Now()
+	proof := credentialProof(cred)
+
+	statusCache.Lock()
+	cached, ok := statusCache.statuses[id]
+	statusCache.Unlock()
+
+	var status CredentialStatus
+	if ok && !refresh && cached.proof == proof && now.Before(cached.expiresAt) {
+		status = cached.status
+	} else {
+		checked, err := checkCredentialStatus(cred, refresh)
+		if err != nil {
+			return nil, err
+		}
+		status = *checked
+
+		statusCache.Lock()
+		for key, entry := range statusCache.statuses {
+			if !now.Before(entry.expiresAt) {
+				delete(statusCache.statuses, key)
+			}
+		}
+		statusCache.statuses[id] = cachedStatus{
+			status:    status,
+			proof:     proof,
+			expiresAt: now.Add(CREDENTIAL_STATUS_TTL),
+		}
+		statusCache.Unlock()
+	}
+
+	status.Validity = StatusCheck{OK: true}
+	err := cred.CheckValidityPeriod(now)
+	if err != nil {
+		status.Validity = StatusCheck{Message: err.Error()}
+	}
+
+	status.Valid = status.IssuerDID.OK && status.Signature.OK && status.Validity.OK && status.Revocation.OK
+
+	return &status, nil
+}
+
+// checkCredentialStatus resolves the issuer's DID document, signature, endorsements, accreditation and revocation list.
+// A check that fails is reported in the status, an error is only returned if the wallet's trust config cannot be loaded.
+// The trust registry is fetched again if refresh is set.
+func checkCredentialStatus(cred *common.VerifiableCredential, refresh bool) (*CredentialStatus, error) {
+	status := CredentialStatus{
+		CheckedAt: time.Now().UTC(),
+	}
+
+	trust, err := loadTrustConfig()
+	if err != nil {
+		return nil, common.ChainError("error loading trust config", err)
+	}
+
+	if trust.RegistryURL != "" {
+		registry, err := getTrustRegistry(trust, refresh)
+		if err != nil {
+			common.LogChainError("error fetching trust registry", err)
+		} else {
+			accredited := registry.IsAccredited(cred.CredType, cred.Issuer.DID)
+			status.Accredited = &accredited
+		}
+	}
+
+	doc, err := common.LoadDIDDocumentFromURI(cred.Issuer.DID)
+	if err != nil {
+		common.LogChainError("error resolving issuer DID", err)
+
+		status.IssuerDID.Message = "issuer DID document could not be loaded"
+		if errors.Is(err, common.ErrInvalidDID) || errors.Is(err, common.ErrMethodNotSupported) || errors.Is(err, common.ErrDIDNotFound) {
+			status.IssuerDID.Message = "issuer DID could not be resolved"
+		}
+
+		//the remaining checks all need the issuer's DID document
+		status.Signature.Message = "issuer DID is unavailable"
+		status.Revocation.Message = "issuer DID is unavailable"
+		status.Trust.Message = "issuer DID is unavailable"
+		return &status, nil
+	}
+	status.IssuerDID.OK = true
+
+	status.Signature.OK = true
+	err = verifier.VerifyIssuerSignature(cred)
+	if errors.Is(err, common.ErrInvalidDisclosure) {
+		status.Signature = StatusCheck{Message: "disclosed fields do not match the issuer signature"}
+	} else if errors.Is(err, verifier.ErrIssuerSignature) {
+		status.Signature = StatusCheck{Message: "issuer signature is invalid"}
+	} else if err != nil {
+		common.LogChainError("error verifying issuer signature", err)
+		status.Signature = StatusCheck{Message: "issuer signature could not be checked"}
+	}
+
+	status.Revocation.OK = true
+	err = common.CheckRevocationStatus(cred)
+	if errors.Is(err, common.ErrCredentialRevoked) {
+		status.Revocation = StatusCheck{Message: err.Error()}
+	} else if err != nil {
+		common.LogChainError("error checking revocation status", err)
+		status.Revocation = StatusCheck{Message: "revocation status could not be checked"}
+	}
+
+	status.TrustChain, err = common.FindTrustChain(doc, trust.Anchors, trust.MaxDepth)
+	if err == nil {
+		status.Trust.OK = true
+	} else if errors.Is(err, common.ErrUntrusted) {
+		status.Trust.Message = "issuer is not endorsed by a trust anchor"
+	} else {
+		common.LogChainError("error finding trust chain", err)
+		status.Trust.Message = "issuer endorsements could not be checked"
+	}
+
+	return &status, nil
+}
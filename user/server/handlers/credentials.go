@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"errors"
+	"time"
+	"vcd/common"
+)
+
+var ErrCredentialNotFound = errors.New("credential not found")
+
+// CredentialsMap holds the wallet's credentials keyed by credential ID.
+// Credentials issued without an ID keep the key they were stored under.
+type CredentialsMap map[string]common.VerifiableCredential
+
+// CredentialFilter selects credentials, unset fields match any credential.
+type CredentialFilter struct {
+	Issuer   string
+	CredType string
+
+	//name of a credential field the credential must have, with the value FieldValue if that is set
+	Field      string
+	FieldValue string
+
+	//whether the credential must be expired or unexpired at Now
+	Expired *bool
+	//only credentials that expire before this time
+	ExpiresBefore *time.Time
+
+	Now time.Time
+}
+
+func (f *CredentialFilter) Matches(cred *common.VerifiableCredential) bool {
+	if f.Issuer != "" && cred.Issuer.DID != f.Issuer {
+		return false
+	}
+	if f.CredType != "" && cred.CredType != f.CredType {
+		return false
+	}
+
+	if f.Field != "" {
+		value, ok := cred.Credentials[f.Field]
+		if !ok || (f.FieldValue != "" && value != f.FieldValue) {
+			return false
+		}
+	}
+
+	if f.Expired != nil {
+		expired := cred.ExpiresAt != nil && !f.Now.Before(*cred.ExpiresAt)
+		if expired != *f.Expired {
+			return false
+		}
+	}
+	if f.ExpiresBefore != nil && (cred.ExpiresAt == nil || !cred.ExpiresAt.Before(*f.ExpiresBefore)) {
+		return false
+	}
+
+	return true
+}
+
+// rekeyed returns the credentials keyed by their IDs.
+// Wallets from before multiple credentials per issuer were supported keyed credentials by issuer DID.
+func (m CredentialsMap) rekeyed() CredentialsMap {
+	res := CredentialsMap{}
+
+	for key, cred := range m {
+		if cred.ID != "" {
+			key = cred.ID
+		}
+		res[key] = cred
+	}
+
+	return res
+}
+
+func (m CredentialsMap) filter(filter CredentialFilter) CredentialsMap {
+	res := CredentialsMap{}
+
+	for id, cred := range m {
+		if filter.Matches(&cred) {
+			res[id] = cred
+		}
+	}
+
+	return res
+}
+
+func (m CredentialsMap) get(id string) (*common.VerifiableCredential, error) {
+	cred, ok := m[id]
+	if !ok {
+		return nil, ErrCredentialNotFound
+	}
+
+	return &cred, nil
+}
+
+// add stores the credential under its ID, replacing an earlier copy of the same credential, and returns the ID.
+func (m CredentialsMap) add(cred *common.VerifiableCredential) (string, error) {
+	id, err := credentialKey(cred)
+	if err != nil {
+		return "", err
+	}
+
+	m[id] = *cred
+	return id, nil
+}
+
+// credentialKey returns the key a new credential is stored under in the wallet,
+// which is its ID or a new random key if it has none so it does not replace another credential.
+func credentialKey(cred *common.VerifiableCredential) (string, error) {
+	if cred.ID != "" {
+		return cred.ID, nil
+	}
+
+	id, err := common.GenerateUUID()
+	if err != nil {
+		return "", common.ChainError("error generating credential key", err)
+	}
+
+	return id, nil
+}
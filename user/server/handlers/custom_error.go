@@ -1,9 +1,17 @@
 package handlers
 
+import (
+	"log"
+	"net/http"
+	"vcd/common"
+	"vcd/verifier"
+)
+
 const (
 	TypeNoError       = iota
 	TypeClientError   = iota
 	TypeInternalError = iota
+	TypeLockedError   = iota
 )
 
 type CustomError struct {
@@ -30,3 +38,31 @@ func InternalError() CustomError {
 		Message: "An internal error occurred.",
 	}
 }
+
+func LockedError() CustomError {
+	return CustomError{
+		Type:    TypeLockedError,
+		Message: "Wallet is locked, unlock it first.",
+	}
+}
+
+// sendCustomError sends the error response for a client, internal or locked error.
+func sendCustomError(w http.ResponseWriter, cerr CustomError) {
+	switch cerr.Type {
+	case TypeClientError:
+		common.SendErrorResponse(w, http.StatusBadRequest, cerr.Message)
+	case TypeLockedError:
+		common.SendErrorResponse(w, http.StatusLocked, cerr.Message)
+	default:
+		common.SendInternalErrorResponse(w)
+	}
+}
+
+func verificationError(err error) CustomError {
+	log.Println(err)
+
+	if verifier.IsVerificationError(err) {
+		return ClientError("Credential could not be verified: " + err.Error())
+	}
+	return InternalError()
+}
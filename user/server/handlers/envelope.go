@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"strconv"
+	"vcd/common"
+
+	"golang.org/x/crypto/argon2"
+)
+
+const (
+	KDF_ARGON2ID = "argon2id"
+
+	//argon2id parameters recommended by RFC 9106 for memory constrained environments
+	ARGON2_TIME    = 3
+	ARGON2_MEMORY  = 64 * 1024
+	ARGON2_THREADS = 4
+	ARGON2_KEY_LEN = 32
+	ARGON2_SALT    = 16
+
+	//limits on the parameters read from an envelope, so an untrusted backup cannot force huge allocations or run times
+	ARGON2_MAX_TIME    = 10
+	ARGON2_MAX_MEMORY  = 256 * 1024
+	ARGON2_MAX_THREADS = 16
+	ENVELOPE_MIN_SALT  = 8
+	ENVELOPE_MAX_SALT  = 64
+
+	ENVELOPE_VERSION = 1
+)
+
+var ErrInvalidEnvelope = errors.New("invalid encrypted envelope")
+
+// encryptedEnvelope holds data encrypted with AES-256-GCM under a key derived from a passphrase with argon2id.
+// The key derivation parameters are stored so they can be raised without breaking existing envelopes.
+type encryptedEnvelope struct {
+	Version    int    `json:"version"`
+	KDF        string `json:"kdf"`
+	Salt       []byte `json:"salt"`
+	Time       uint32 `json:"time"`
+	Memory     uint32 `json:"memory"`
+	Threads    uint8  `json:"threads"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// newEnvelope creates an empty envelope with a random salt and the current key derivation parameters.
+func newEnvelope() (*encryptedEnvelope, error) {
+	salt := make([]byte, ARGON2_SALT)
+	_, err := rand.Read(salt)
+	if err != nil {
+		return nil, common.ChainError("error generating salt", err)
+	}
+
+	return &encryptedEnvelope{
+		Version: ENVELOPE_VERSION,
+		KDF:     KDF_ARGON2ID,
+		Salt:    salt,
+		Time:    ARGON2_TIME,
+		Memory:  ARGON2_MEMORY,
+		Threads: ARGON2_THREADS,
+	}, nil
+}
+
+// validate checks the envelope's version and key derivation parameters before they are used,
+// returning ErrInvalidEnvelope if they are unsupported or outside the limits.
+func (e *encryptedEnvelope) validate() error {
+	if e.Version != ENVELOPE_VERSION {
+		return common.ChainError("unsupported envelope version "+strconv.Itoa(e.Version), ErrInvalidEnvelope)
+	}
+	if e.KDF != KDF_ARGON2ID {
+		return common.ChainError("unsupported key derivation function "+e.KDF, ErrInvalidEnvelope)
+	}
+	if e.Time < 1 || e.Time > ARGON2_MAX_TIME {
+		return common.ChainError("argon2 time parameter out of range", ErrInvalidEnvelope)
+	}
+	if e.Threads < 1 || e.Threads > ARGON2_MAX_THREADS {
+		return common.ChainError("argon2 threads parameter out of range", ErrInvalidEnvelope)
+	}
+	//argon2 needs at least 8 KiB per thread
+	if e.Memory < 8*uint32(e.Threads) || e.Memory > ARGON2_MAX_MEMORY {
+		return common.ChainError("argon2 memory parameter out of range", ErrInvalidEnvelope)
+	}
+	if len(e.Salt) < ENVELOPE_MIN_SALT || len(e.Salt) > ENVELOPE_MAX_SALT {
+		return common.ChainError("salt length out of range", ErrInvalidEnvelope)
+	}
+
+	return nil
+}
+
+func (e *encryptedEnvelope) deriveKey(passphrase string) ([]byte, error) {
+	err := e.validate()
+	if err != nil {
+		return nil, err
+	}
+
+	return argon2.IDKey([]byte(passphrase), e.Salt, e.Time, e.Memory, e.Threads, ARGON2_KEY_LEN), nil
+}
+
+// seal encrypts the plaintext under a fresh nonce, authenticating the additional data with it.
+func (e *encryptedEnvelope) seal(key []byte, plaintext []byte, additionalData []byte) error {
+	gcm, err := newEnvelopeCipher(key)
+	if err != nil {
+		return err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return common.ChainError("error generating nonce", err)
+	}
+
+	e.Nonce = nonce
+	e.Ciphertext = gcm.Seal(nil, nonce, plaintext, additionalData)
+
+	return nil
+}
+
+// open decrypts the envelope, returning ErrWrongPassphrase if it fails to authenticate.
+func (e *encryptedEnvelope) open(key []byte, additionalData []byte) ([]byte, error) {
+	gcm, err := newEnvelopeCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(e.Nonce) != gcm.NonceSize() {
+		return nil, common.ChainError("nonce has the wrong length", ErrInvalidEnvelope)
+	}
+
+	plaintext, err := gcm.Open(nil, e.Nonce, e.Ciphertext, additionalData)
+	if err != nil {
+		//authentication fails if the key was derived from the wrong passphrase or the envelope was modified
+		return nil, ErrWrongPassphrase
+	}
+
+	return plaintext, nil
+}
+
+func newEnvelopeCipher(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, common.ChainError("error creating cipher", err)
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, common.ChainError("error creating GCM", err)
+	}
+
+	return gcm, nil
+}
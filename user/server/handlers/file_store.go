@@ -0,0 +1,427 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+	"vcd/common"
+)
+
+const ENCRYPTED_WALLET_URI = "wallet/wallet.enc.json"
+
+// walletContents is the plaintext sealed inside the wallet file's envelope.
+type walletContents struct {
+	PrivateKey  string         `json:"private_key"`
+	Credentials CredentialsMap `json:"credentials"`
+	ConsentLog  ConsentLog     `json:"consent_log"`
+}
+
+// EncryptedFileStore keeps the credentials, private key and consent log in a single file encrypted with AES-256-GCM,
+// under a key derived from the wallet passphrase with argon2id.
+// The derived key is only held in memory while the store is unlocked.
+// Updates are serialized and written atomically with a backup.
+type EncryptedFileStore struct {
+	mu  sync.Mutex
+	uri string
+
+	//plaintext files migrated into the store when it is first unlocked
+	legacyCredsURI      string
+	legacyPrivateKeyURI string
+	legacyConsentURI    string
+
+	//the DID file is kept in plaintext, so the DID is known while the store is locked
+	didURI string
+
+	key      []byte
+	envelope *encryptedEnvelope
+}
+
+// NewEncryptedFileStore opens the wallet file at the URI, migrating the plaintext wallet files at the paths into it when it is created.
+func NewEncryptedFileStore(uri string, paths WalletPaths) *EncryptedFileStore {
+	return &EncryptedFileStore{
+		uri:                 uri,
+		legacyCredsURI:      paths.Credentials,
+		legacyPrivateKeyURI: paths.PrivateKey,
+		legacyConsentURI:    paths.ConsentLog,
+		didURI:              paths.DID,
+	}
+}
+
+// Unlock derives the key from the passphrase and checks it against the wallet file.
+// If there is no wallet file yet, one is created with the passphrase,
+// moving any plaintext credentials, private key and consent log into it or generating a new key.
+func (s *EncryptedFileStore) Unlock(passphrase string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	envelope := encryptedEnvelope{}
+	err := loadWalletFile(s.uri, &envelope)
+	if errors.Is(err, os.ErrNotExist) {
+		return s.create(passphrase)
+	}
+	if err != nil {
+		return common.ChainError("error loading encrypted wallet", err)
+	}
+
+	key, err := envelope.deriveKey(passphrase)
+	if err != nil {
+		return err
+	}
+
+	_, err = decryptWallet(key, &envelope)
+	if err != nil {
+		return err
+	}
+
+	s.key = key
+	s.envelope = &envelope
+
+	return nil
+}
+
+func (s *EncryptedFileStore) Lock() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.key {
+		s.key[i] = 0
+	}
+	s.key = nil
+	s.envelope = nil
+}
+
+func (s *EncryptedFileStore) IsLocked() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.key == nil
+}
+
+func (s *EncryptedFileStore) ListCredentials(filter CredentialFilter) (CredentialsMap, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	contents, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+
+	return contents.Credentials.filter(filter), nil
+}
+
+func (s *EncryptedFileStore) GetCredential(id string) (*common.VerifiableCredential, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	contents, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+
+	return contents.Credentials.get(id)
+}
+
+func (s *EncryptedFileStore) AddCredential(cred *common.VerifiableCredential) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	contents, err := s.load()
+	if err != nil {
+		return "", err
+	}
+
+	id, err := contents.Credentials.add(cred)
+	if err != nil {
+		return "", err
+	}
+
+	return id, s.save(contents)
+}
+
+func (s *EncryptedFileStore) LoadSigner() (common.Signer, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	contents, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+
+	return common.ParseSigner([]byte(contents.PrivateKey), "")
+}
+
+func (s *EncryptedFileStore) LoadPrivateKey() ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	contents, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte(contents.PrivateKey), nil
+}
+
+func (s *EncryptedFileStore) LoadDID() (string, error) {
+	return readDIDFile(s.didURI)
+}
+
+// Restore replaces the contents of the wallet file and the DID file together.
+func (s *EncryptedFileStore) Restore(bundle *WalletBundle) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.key == nil {
+		return ErrWalletLocked
+	}
+
+	walletUpdate, err := s.seal(&walletContents{
+		PrivateKey:  bundle.PrivateKey,
+		Credentials: bundle.Credentials,
+		ConsentLog:  bundle.ConsentLog,
+	})
+	if err != nil {
+		return err
+	}
+
+	didUpdate, err := didFileUpdate(s.didURI, bundle.DID, []byte(bundle.PrivateKey))
+	if err != nil {
+		return err
+	}
+
+	err = writeWalletFiles([]walletFileUpdate{walletUpdate, didUpdate})
+	if err != nil {
+		return common.ChainError("error writing encrypted wallet", err)
+	}
+
+	return nil
+}
+
+// SetPrivateKey replaces the key in the wallet file, re-authenticating the consent log, and the DID file together.
+func (s *EncryptedFileStore) SetPrivateKey(privateKey []byte, DID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	contents, err := s.load()
+	if err != nil {
+		return err
+	}
+	err = contents.ConsentLog.rekey([]byte(contents.PrivateKey), privateKey)
+	if err != nil {
+		return err
+	}
+	contents.PrivateKey = string(privateKey)
+
+	walletUpdate, err := s.seal(contents)
+	if err != nil {
+		return err
+	}
+
+	didUpdate, err := didFileUpdate(s.didURI, DID, privateKey)
+	if err != nil {
+		return err
+	}
+
+	err = writeWalletFiles([]walletFileUpdate{walletUpdate, didUpdate})
+	if err != nil {
+		return common.ChainError("error writing encrypted wallet", err)
+	}
+
+	return nil
+}
+
+func (s *EncryptedFileStore) AppendConsent(entry *ConsentEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	contents, err := s.load()
+	if err != nil {
+		return err
+	}
+
+	err = contents.ConsentLog.check([]byte(contents.PrivateKey))
+	if err != nil {
+		return err
+	}
+
+	err = contents.ConsentLog.append(entry, []byte(contents.PrivateKey))
+	if err != nil {
+		return err
+	}
+
+	return s.save(contents)
+}
+
+func (s *EncryptedFileStore) ListConsent() (*ConsentLog, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	contents, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+
+	return &contents.ConsentLog, nil
+}
+
+// load decrypts the wallet file, the caller must hold s.mu.
+func (s *EncryptedFileStore) load() (*walletContents, error) {
+	if s.key == nil {
+		return nil, ErrWalletLocked
+	}
+
+	envelope := encryptedEnvelope{}
+	err := loadWalletFile(s.uri, &envelope)
+	if err != nil {
+		return nil, common.ChainError("error loading encrypted wallet", err)
+	}
+
+	return decryptWallet(s.key, &envelope)
+}
+
+// save encrypts the contents under a fresh nonce and writes the wallet file, the caller must hold s.mu.
+func (s *EncryptedFileStore) save(contents *walletContents) error {
+	update, err := s.seal(contents)
+	if err != nil {
+		return err
+	}
+
+	err = writeWalletFiles([]walletFileUpdate{update})
+	if err != nil {
+		return common.ChainError("error writing encrypted wallet", err)
+	}
+
+	return nil
+}
+
+// seal encrypts the contents under a fresh nonce into an update of the wallet file, the caller must hold s.mu.
+func (s *EncryptedFileStore) seal(contents *walletContents) (walletFileUpdate, error) {
+	plaintext, err := json.Marshal(contents)
+	if err != nil {
+		return walletFileUpdate{}, common.ChainError("error marshalling wallet contents", err)
+	}
+
+	envelope := *s.envelope
+	err = envelope.seal(s.key, plaintext, nil)
+	if err != nil {
+		return walletFileUpdate{}, err
+	}
+
+	buffer, err := common.EncodeJSON(&envelope)
+	if err != nil {
+		return walletFileUpdate{}, err
+	}
+
+	return walletFileUpdate{uri: s.uri, data: buffer.Bytes(), backup: true}, nil
+}
+
+// create initialises a new wallet file, the caller must hold s.mu.
+func (s *EncryptedFileStore) create(passphrase string) error {
+	contents, err := s.loadLegacyContents()
+	if err != nil {
+		return common.ChainError("error loading plaintext wallet", err)
+	}
+
+	envelope, err := newEnvelope()
+	if err != nil {
+		return err
+	}
+
+	key, err := envelope.deriveKey(passphrase)
+	if err != nil {
+		return err
+	}
+
+	s.envelope = envelope
+	s.key = key
+
+	err = s.save(contents)
+	if err != nil {
+		s.key = nil
+		s.envelope = nil
+		return err
+	}
+
+	//only remove the plaintext files once they are safely in the encrypted wallet
+	plaintextURIs := []string{
+		s.legacyCredsURI, s.legacyCredsURI + BACKUP_EXT,
+		s.legacyConsentURI, s.legacyConsentURI + BACKUP_EXT,
+		s.legacyPrivateKeyURI, s.legacyPrivateKeyURI + BACKUP_EXT,
+	}
+	for _, uri := range plaintextURIs {
+		err = os.Remove(uri)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return common.ChainError("error removing plaintext wallet file", err)
+		}
+	}
+
+	return nil
+}
+
+// loadLegacyContents reads the plaintext wallet files if they exist, generating a private key if there is none.
+func (s *EncryptedFileStore) loadLegacyContents() (*walletContents, error) {
+	contents := walletContents{
+		Credentials: CredentialsMap{},
+		ConsentLog:  ConsentLog{Entries: []ConsentEntry{}},
+	}
+
+	err := loadWalletFile(s.legacyCredsURI, &contents.Credentials)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, common.ChainError("error loading verifiable credentials", err)
+	}
+	contents.Credentials = contents.Credentials.rekeyed()
+
+	err = loadWalletFile(s.legacyConsentURI, &contents.ConsentLog)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, common.ChainError("error loading consent log", err)
+	}
+
+	bytes, err := os.ReadFile(s.legacyPrivateKeyURI)
+	if err == nil {
+		//make sure the key is usable before it is moved into the store
+		_, err = common.ParseSigner(bytes, "")
+		if err != nil {
+			return nil, common.ChainError("error parsing private key", err)
+		}
+
+		contents.PrivateKey = string(bytes)
+		return &contents, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, common.ChainError("error reading private key file", err)
+	}
+
+	key, err := common.GenerateKey("ed25519")
+	if err != nil {
+		return nil, common.ChainError("error generating private key", err)
+	}
+
+	bytes, err = common.EncodePrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	contents.PrivateKey = string(bytes)
+	return &contents, nil
+}
+
+func decryptWallet(key []byte, envelope *encryptedEnvelope) (*walletContents, error) {
+	plaintext, err := envelope.open(key, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	contents := walletContents{}
+	err = json.Unmarshal(plaintext, &contents)
+	if err != nil {
+		return nil, common.ChainError("error unmarshalling wallet contents", err)
+	}
+
+	contents.Credentials = contents.Credentials.rekeyed()
+	if contents.ConsentLog.Entries == nil {
+		contents.ConsentLog.Entries = []ConsentEntry{}
+	}
+
+	return &contents, nil
+}
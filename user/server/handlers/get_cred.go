@@ -1,25 +1,57 @@
 package handlers
 
 import (
+	"errors"
+	"log"
 	"net/http"
+	"strconv"
 	"vcd/common"
 )
 
+type CredentialDetails struct {
+	common.VerifiableCredential
+	Status CredentialStatus `json:"status"`
+}
+
 func GetCredHandler(w http.ResponseWriter, req *http.Request) {
-	id := req.URL.Query().Get("id")
+	query := req.URL.Query()
+
+	id := query.Get("id")
+	if id == "" {
+		common.SendErrorResponse(w, http.StatusBadRequest, "missing required parameter 'id'")
+		return
+	}
+
+	refresh := false
+	if v := query.Get("refresh"); v != "" {
+		var err error
+		refresh, err = strconv.ParseBool(v)
+		if err != nil {
+			common.SendErrorResponse(w, http.StatusBadRequest, "invalid parameter 'refresh'")
+			return
+		}
+	}
 
-	creds := CredentialsMap{}
-	err := common.LoadJSONFromFile(VC_URI, &creds)
+	cred, err := Store.GetCredential(id)
+	if errors.Is(err, ErrCredentialNotFound) {
+		log.Println("credential with id", id, "no found")
+		common.SendErrorResponse(w, http.StatusBadRequest, "No credential found for ID.")
+		return
+	}
 	if err != nil {
-		common.LogChainError("error loading verifiable credentials", err)
-		common.SendInternalErrorResponse(w)
+		sendCustomError(w, storeError("error loading verifiable credential", err))
 		return
 	}
 
-	cred, ok := creds[id]
-	if !ok {
-		common.SendSuccessResponse(w)
+	status, err := getCredentialStatus(id, cred, refresh)
+	if err != nil {
+		common.LogChainError("error checking credential status", err)
+		common.SendInternalErrorResponse(w)
+		return
 	}
 
-	common.SendJSONResponse(w, http.StatusOK, &cred)
+	common.SendJSONResponse(w, http.StatusOK, &CredentialDetails{
+		VerifiableCredential: *cred,
+		Status:               *status,
+	})
 }
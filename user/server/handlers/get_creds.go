@@ -1,18 +1,100 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
+	"strconv"
+	"time"
 	"vcd/common"
 )
 
-func GetCredsHandler(w http.ResponseWriter, _ *http.Request) {
-	creds := CredentialsMap{}
-	err := common.LoadJSONFromFile(VC_URI, &creds)
+type WalletCredential struct {
+	common.VerifiableCredential
+	Expired bool `json:"expired"`
+
+	//whether the issuer is accredited for the credential type, omitted if no trust registry is configured or it is unavailable
+	Accredited *bool `json:"accredited,omitempty"`
+}
+
+// parseCredentialFilter reads the issuer, cred_type, field, value, expired and expires_before parameters.
+func parseCredentialFilter(req *http.Request) (*CredentialFilter, error) {
+	query := req.URL.Query()
+
+	filter := CredentialFilter{
+		Issuer:     query.Get("issuer"),
+		CredType:   query.Get("cred_type"),
+		Field:      query.Get("field"),
+		FieldValue: query.Get("value"),
+		Now:        time.Now(),
+	}
+
+	if filter.FieldValue != "" && filter.Field == "" {
+		return nil, errors.New("parameter 'value' requires 'field'")
+	}
+
+	if v := query.Get("expired"); v != "" {
+		expired, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, errors.New("invalid parameter 'expired'")
+		}
+		filter.Expired = &expired
+	}
+
+	if v := query.Get("expires_before"); v != "" {
+		before, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return nil, errors.New("invalid parameter 'expires_before'")
+		}
+		filter.ExpiresBefore = &before
+	}
+
+	return &filter, nil
+}
+
+func GetCredsHandler(w http.ResponseWriter, req *http.Request) {
+	filter, err := parseCredentialFilter(req)
 	if err != nil {
-		common.LogChainError("error loading verifiable credentials", err)
+		common.LogChainError("error parsing credential filter", err)
+		common.SendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	creds, err := Store.ListCredentials(*filter)
+	if err != nil {
+		sendCustomError(w, storeError("error loading verifiable credentials", err))
+		return
+	}
+
+	trust, err := loadTrustConfig()
+	if err != nil {
+		common.LogChainError("error loading trust config", err)
 		common.SendInternalErrorResponse(w)
 		return
 	}
 
-	common.SendJSONResponse(w, http.StatusOK, &creds)
+	var registry *common.TrustRegistry
+	if trust.RegistryURL != "" {
+		registry, err = getTrustRegistry(trust, false)
+		if err != nil {
+			common.LogChainError("error fetching trust registry", err)
+		}
+	}
+
+	res := map[string]WalletCredential{}
+
+	for id, cred := range creds {
+		walletCred := WalletCredential{
+			VerifiableCredential: cred,
+			Expired:              cred.CheckValidityPeriod(filter.Now) == common.ErrCredentialExpired,
+		}
+
+		if registry != nil {
+			accredited := registry.IsAccredited(cred.CredType, cred.Issuer.DID)
+			walletCred.Accredited = &accredited
+		}
+
+		res[id] = walletCred
+	}
+
+	common.SendJSONResponse(w, http.StatusOK, &res)
 }
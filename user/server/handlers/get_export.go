@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"vcd/common"
+)
+
+func GetExportHandler(w http.ResponseWriter, req *http.Request) {
+	id := req.URL.Query().Get("id")
+
+	version := 1
+	if v := req.URL.Query().Get("version"); v != "" {
+		var err error
+		version, err = strconv.Atoi(v)
+		if err != nil {
+			common.SendErrorResponse(w, http.StatusBadRequest, "invalid parameter 'version'")
+			return
+		}
+	}
+
+	cred, err := Store.GetCredential(id)
+	if errors.Is(err, ErrCredentialNotFound) {
+		log.Println("credential with id", id, "no found")
+		common.SendErrorResponse(w, http.StatusBadRequest, "No credential found for ID.")
+		return
+	}
+	if err != nil {
+		sendCustomError(w, storeError("error loading verifiable credential", err))
+		return
+	}
+
+	w3c, err := common.ToW3CCredential(cred, version)
+	if err != nil {
+		common.LogChainError("error converting credential to W3C format", err)
+		common.SendErrorResponse(w, http.StatusBadRequest, "Credential could not be exported.")
+		return
+	}
+
+	common.SendJSONResponse(w, http.StatusOK, w3c)
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"net/http"
+	"vcd/common"
+)
+
+type HistoryResponse struct {
+	//whether every entry's hashes match the entries before it under the holder's key, and no entries were removed
+	Intact bool `json:"intact"`
+	//index of the first entry that does not match, or the number of entries if entries were removed from the end,
+	//omitted if the log is intact
+	BrokenAt *int64 `json:"broken_at,omitempty"`
+	//hash of the last entry, which changes if any entry is changed
+	Head string `json:"head,omitempty"`
+
+	Verifiers []VerifierSummary `json:"verifiers"`
+	Entries   []ConsentEntry    `json:"entries"`
+}
+
+func GetHistoryHandler(w http.ResponseWriter, req *http.Request) {
+	consentLog, err := Store.ListConsent()
+	if err != nil {
+		sendCustomError(w, storeError("error loading consent log", err))
+		return
+	}
+
+	privateKey, err := Store.LoadPrivateKey()
+	if err != nil {
+		sendCustomError(w, storeError("error loading private key", err))
+		return
+	}
+
+	brokenAt, err := consentLog.verify(privateKey)
+	if err != nil {
+		common.LogChainError("error verifying consent log", err)
+		common.SendInternalErrorResponse(w)
+		return
+	}
+
+	res := HistoryResponse{
+		Intact:  brokenAt < 0,
+		Entries: consentLog.Entries,
+	}
+	if !res.Intact {
+		res.BrokenAt = &brokenAt
+	}
+	if len(consentLog.Entries) > 0 {
+		res.Head = consentLog.Entries[len(consentLog.Entries)-1].Hash
+	}
+
+	//the whole log is verified, then only the verifier's entries are listed
+	if verifier := req.URL.Query().Get("verifier"); verifier != "" {
+		res.Entries = []ConsentEntry{}
+		for _, entry := range consentLog.Entries {
+			if entry.VerifierDID == verifier {
+				res.Entries = append(res.Entries, entry)
+			}
+		}
+	}
+	res.Verifiers = summarizeConsentLog(res.Entries)
+
+	common.SendJSONResponse(w, http.StatusOK, &res)
+}
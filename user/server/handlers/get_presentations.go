@@ -0,0 +1,22 @@
+package handlers
+
+import (
+	"net/http"
+	"vcd/common"
+)
+
+func GetPresentationsHandler(w http.ResponseWriter, req *http.Request) {
+	history, ok := Store.(PresentationHistory)
+	if !ok {
+		common.SendErrorResponse(w, http.StatusBadRequest, "The wallet storage backend does not keep a presentation history.")
+		return
+	}
+
+	records, err := history.ListPresentations(req.URL.Query().Get("credential_id"))
+	if err != nil {
+		sendCustomError(w, storeError("error loading presentation history", err))
+		return
+	}
+
+	common.SendJSONResponse(w, http.StatusOK, records)
+}
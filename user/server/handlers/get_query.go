@@ -1,8 +1,10 @@
 package handlers
 
 import (
+	"errors"
 	"log"
 	"net/http"
+	"time"
 	"vcd/common"
 )
 
@@ -17,8 +19,11 @@ type QueryResponse struct {
 	Description string                     `json:"description"`
 	Fields      []common.PresentationField `json:"fields,omitempty"`
 
-	Issuer          string `json:"issuer,omitempty"`
-	TrustedByIssuer bool   `json:"trusted_by_issuer"`
+	Requirements    []common.CredentialRequirement `json:"requirements,omitempty"`
+	TrustedByIssuer bool                           `json:"trusted_by_issuer"`
+
+	//endorsements linking the entity to one of the wallet's trust anchors, empty if it is not trusted
+	TrustChain []common.TrustLink `json:"trust_chain,omitempty"`
 }
 
 func GetQueryHandler(w http.ResponseWriter, req *http.Request) {
@@ -59,37 +64,58 @@ func getQuery(url string) (*QueryResponse, CustomError) {
 	}
 
 	doc, err := common.LoadDIDDocumentFromURI(pres.Entity.DID)
+	if errors.Is(err, common.ErrInvalidDID) || errors.Is(err, common.ErrMethodNotSupported) || errors.Is(err, common.ErrDIDNotFound) {
+		common.LogChainError("error resolving entity DID", err)
+		return nil, ClientError("Entity DID could not be resolved.")
+	}
 	if err != nil {
 		common.LogChainError("error loading DID doc", err)
 		return nil, InternalError()
 	}
 
-	DID, err := common.LoadPublicKeyFromDocument(doc)
+	err = common.VerifyDocumentSignature(doc, common.ASSERTION_METHOD, time.Now(), &pres.Entity, &pres)
 	if err != nil {
-		common.LogChainError("error loading public key from DID doc", err)
-		return nil, InternalError()
+		common.LogChainError("error verifying entity signature", err)
+		return nil, ClientError("Entity cannot be verified.")
 	}
 
-	err = common.VerifyStructSignature(DID, &pres.Entity.Signature, &pres)
-	if err != nil {
-		common.LogChainError("error verifying entity signature", err)
+	if pres.Audience != "" && pres.Audience != pres.Entity.DID {
+		log.Println("presentation request audience", pres.Audience, "does not match entity", pres.Entity.DID)
 		return nil, ClientError("Entity cannot be verified.")
 	}
+	savePendingRequest(&pres)
 
 	res := QueryResponse{
-		Type:        pres.Type,
-		ServiceURL:  pres.ServiceURL,
-		DID:         pres.Entity.DID,
-		Name:        pres.EntityName,
-		Domain:      doc.Domain,
-		CredType:    pres.CredType,
-		Description: pres.Description,
-		Fields:      pres.Fields,
-		Issuer:      pres.Issuer,
+		Type:         pres.Type,
+		ServiceURL:   pres.ServiceURL,
+		DID:          pres.Entity.DID,
+		Name:         pres.EntityName,
+		Domain:       doc.GetDomain(),
+		CredType:     pres.CredType,
+		Description:  pres.Description,
+		Fields:       pres.Fields,
+		Requirements: pres.Requirements,
 	}
 
-	if pres.Issuer != "" {
-		res.TrustedByIssuer = (common.VerifyDIDDocumentSignature(doc, pres.Issuer) == nil)
+	trust, err := loadTrustConfig()
+	if err != nil {
+		common.LogChainError("error loading trust config", err)
+		return nil, InternalError()
+	}
+
+	res.TrustChain, err = common.FindTrustChain(doc, trust.Anchors, trust.MaxDepth)
+	if err != nil && !errors.Is(err, common.ErrUntrusted) {
+		common.LogChainError("error finding trust chain", err)
+	}
+
+	//trusted if endorsed, directly or through other entities, by any of the accepted issuers
+	issuers := []string{}
+	for _, req := range pres.Requirements {
+		issuers = append(issuers, req.Issuers...)
+	}
+	if len(issuers) > 0 {
+		_, err = common.FindTrustChain(doc, issuers, trust.MaxDepth)
+		res.TrustedByIssuer = (err == nil)
 	}
 
 	return &res, NoError()
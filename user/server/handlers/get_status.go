@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"vcd/common"
+)
+
+type StatusResponse struct {
+	Locked bool `json:"locked"`
+
+	//the holder DID, omitted while the wallet is locked unless it is configured in DID_URI
+	DID string `json:"did,omitempty"`
+}
+
+func GetStatusHandler(w http.ResponseWriter, _ *http.Request) {
+	res := StatusResponse{
+		Locked: Store.IsLocked(),
+	}
+
+	DID, err := loadHolderDID()
+	if err == nil {
+		res.DID = DID
+	} else if !errors.Is(err, ErrWalletLocked) {
+		common.LogChainError("error loading holder DID", err)
+	}
+
+	common.SendJSONResponse(w, http.StatusOK, &res)
+}
@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"sort"
+	"time"
+	"vcd/common"
+)
+
+// PresentationHistory is implemented by wallet stores that keep a history of the presentations made with their credentials.
+type PresentationHistory interface {
+	RecordPresentation(record *PresentationRecord) error
+	// ListPresentations returns the presentations newest first, only those including the credential if credentialID is set.
+	ListPresentations(credentialID string) ([]PresentationRecord, error)
+}
+
+type PresentationRecord struct {
+	ID          int64                 `json:"id"`
+	ServiceURL  string                `json:"service_url"`
+	Audience    string                `json:"audience"`
+	Nonce       string                `json:"nonce"`
+	PresentedAt time.Time             `json:"presented_at"`
+	Accepted    bool                  `json:"accepted"`
+	Credentials []PresentedCredential `json:"credentials"`
+}
+
+// PresentedCredential is a credential included in a presentation and the fields that were disclosed from it.
+type PresentedCredential struct {
+	ID       string   `json:"id"`
+	CredType string   `json:"cred_type"`
+	Issuer   string   `json:"issuer"`
+	Fields   []string `json:"fields"`
+}
+
+// newPresentedCredential describes the disclosed credential, listing the fields it contains as they were sent.
+func newPresentedCredential(id string, cred *common.VerifiableCredential) PresentedCredential {
+	fields := []string{}
+	for name := range cred.Credentials {
+		fields = append(fields, name)
+	}
+	sort.Strings(fields)
+
+	return PresentedCredential{
+		ID:       id,
+		CredType: cred.CredType,
+		Issuer:   cred.Issuer.DID,
+		Fields:   fields,
+	}
+}
@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"vcd/common"
+)
+
+type PostBackupBody struct {
+	Passphrase string `json:"passphrase"`
+}
+
+func PostBackupHandler(w http.ResponseWriter, req *http.Request) {
+	body := PostBackupBody{}
+
+	err := common.DecodeJSON(req.Body, &body)
+	if err != nil {
+		common.LogChainError("error decoding post backup body", err)
+		common.SendErrorResponse(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+
+	if body.Passphrase == "" {
+		common.SendErrorResponse(w, http.StatusBadRequest, "A passphrase is required.")
+		return
+	}
+
+	backup, err := createBackup(body.Passphrase)
+	if errors.Is(err, ErrConsentLogBroken) {
+		common.LogChainError("error creating wallet backup", err)
+		sendCustomError(w, ClientError("The consent log has been changed, so the wallet cannot be backed up."))
+		return
+	}
+	if err != nil {
+		sendCustomError(w, storeError("error creating wallet backup", err))
+		return
+	}
+
+	common.SendJSONResponse(w, http.StatusOK, backup)
+}
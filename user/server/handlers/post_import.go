@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"log"
+	"mime"
+	"net/http"
+	"vcd/common"
+	"vcd/verifier"
+)
+
+// PostImportHandler imports a W3C credential, or a JWT-VC sent as the compact JWT followed by its disclosures
+// with the application/jwt content type.
+func PostImportHandler(w http.ResponseWriter, req *http.Request) {
+	var cred *common.VerifiableCredential
+
+	mediaType, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	if mediaType == common.JWT_MEDIA_TYPE {
+		var err error
+		cred, err = common.DecodeCredential(req.Body)
+		if err != nil {
+			common.LogChainError("error decoding JWT credential", err)
+			common.SendErrorResponse(w, http.StatusBadRequest, "Invalid JWT credential.")
+			return
+		}
+	} else {
+		w3c := common.W3CCredential{}
+
+		err := common.DecodeJSON(req.Body, &w3c)
+		if err != nil {
+			common.LogChainError("error decoding post import body", err)
+			common.SendErrorResponse(w, http.StatusBadRequest, "invalid JSON body")
+			return
+		}
+
+		cred, err = common.FromW3CCredential(&w3c)
+		if err != nil {
+			common.LogChainError("error converting W3C credential", err)
+			common.SendErrorResponse(w, http.StatusBadRequest, "Invalid W3C credential.")
+			return
+		}
+	}
+
+	cerr := postImport(cred)
+	if cerr.Type != TypeNoError {
+		sendCustomError(w, cerr)
+		return
+	}
+
+	common.SendSuccessResponse(w)
+}
+
+func postImport(cred *common.VerifiableCredential) CustomError {
+	DID, err := loadHolderDID()
+	if err != nil {
+		return storeError("error loading holder DID", err)
+	}
+
+	if cred.Subject.DID != DID {
+		log.Println("imported credential subject does not match wallet DID")
+		return ClientError("Credential subject is not this wallet.")
+	}
+
+	err = verifier.VerifyCredential(cred)
+	if err != nil {
+		return verificationError(err)
+	}
+
+	_, err = Store.AddCredential(cred)
+	if err != nil {
+		return storeError("error saving verifiable credentials", err)
+	}
+
+	return NoError()
+}
@@ -1,14 +1,13 @@
 package handlers
 
 import (
+	"errors"
 	"log"
 	"net/http"
-	"os"
+	"time"
 	"vcd/common"
 )
 
-const DID_URI = "wallet/DID.cert"
-
 type IssuePostBody struct {
 	ServiceURL   string            `json:"service_url"`
 	Type         string            `json:"type"`
@@ -16,6 +15,13 @@ type IssuePostBody struct {
 	CredentialID string            `json:"credential_id,omitempty"`
 }
 
+type PostIssueResponse struct {
+	Success bool `json:"success"`
+
+	//wallet ID of the new credential
+	ID string `json:"id"`
+}
+
 func PostIssueHandler(w http.ResponseWriter, req *http.Request) {
 	body := IssuePostBody{}
 
@@ -26,82 +32,115 @@ func PostIssueHandler(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	cerr := postIssue(&body)
-	if cerr.Type == TypeClientError {
-		common.SendErrorResponse(w, http.StatusBadRequest, cerr.Message)
-		return
-	}
-	if cerr.Type == TypeInternalError {
-		common.SendInternalErrorResponse(w)
+	id, cerr := postIssue(&body)
+	if cerr.Type != TypeNoError {
+		sendCustomError(w, cerr)
 		return
 	}
 
-	common.SendSuccessResponse(w)
+	common.SendJSONResponse(w, http.StatusOK, &PostIssueResponse{
+		Success: true,
+		ID:      id,
+	})
 }
 
-func postIssue(body *IssuePostBody) CustomError {
+func postIssue(body *IssuePostBody) (string, CustomError) {
 	cred := common.VerifiableCredential{}
 
+	DID, err := loadHolderDID()
+	if err != nil {
+		return "", storeError("error loading holder DID", err)
+	}
+
+	//a form request is left pending so the form can be submitted again, a request for a credential consumes its nonce
+	var pres *common.PresentationRequest
+	var ok bool
 	if body.Type == "iss:form" {
-		bytes, err := os.ReadFile(DID_URI)
-		if err != nil {
-			common.LogChainError("error reading DID file", err)
-			return InternalError()
-		}
-		cred.Subject.DID = string(bytes)
+		pres, ok = getPendingRequest(body.ServiceURL)
+	} else {
+		pres, ok = takePendingRequest(body.ServiceURL)
+	}
+	if !ok {
+		log.Println("no pending presentation request for", body.ServiceURL)
+		return "", ClientError("Request has expired, please query the service again.")
+	}
+	if pres.Type != body.Type {
+		log.Println("issue type", body.Type, "does not match presentation request type", pres.Type)
+		return "", ClientError("Request type does not match the service's request.")
+	}
+
+	if body.Type == "iss:form" {
+		cred.Subject.DID = DID
 
 		cred.Credentials = body.Fields
 
 	} else { //iss:cred
-		creds, err := loadVerifiableCredentials()
+		existing, err := Store.GetCredential(body.CredentialID)
+		if errors.Is(err, ErrCredentialNotFound) {
+			log.Println("credential with id", body.CredentialID, "no found")
+			return "", ClientError("No credential found for ID.")
+		}
 		if err != nil {
-			common.LogChainError("error loading verifiable credentials", err)
-			return InternalError()
+			return "", storeError("error loading verifiable credential", err)
 		}
 
-		var ok bool
-		cred, ok = (*creds)[body.CredentialID]
-		if !ok {
-			log.Println("credential with id", body.CredentialID, "no found")
-			return ClientError("No credential found for ID.")
+		disclosed, err := discloseRequiredFields(pres.Requirements, []common.VerifiableCredential{*existing})
+		if err != nil {
+			common.LogChainError("error disclosing required fields", err)
+			return "", ClientError("Selected credential does not satisfy the request.")
 		}
+		cred = disclosed[0]
+	}
+
+	signer, err := Store.LoadSigner()
+	if err != nil {
+		return "", storeError("error loading private key", err)
 	}
 
-	err := common.SignStruct(PRIVATE_KEY_URI, &cred.Subject, cred)
+	err = common.SignStructWithSigner(signer, &cred.Subject, &cred)
 	if err != nil {
 		common.LogChainError("error signing issue request", err)
-		return InternalError()
+		return "", InternalError()
 	}
 
+	presentedAt := time.Now().UTC()
 	res, cerr, err := sendRequest(http.MethodPost, body.ServiceURL, &cred)
 	if err != nil {
 		log.Println(err)
 	}
+
+	//credentials from the wallet were disclosed to the issuer, form fields are entered by the user
+	if body.Type == "iss:cred" {
+		recordPresentation(pres, &PresentationRecord{
+			ServiceURL:  body.ServiceURL,
+			Audience:    pres.Audience,
+			Nonce:       pres.Nonce,
+			PresentedAt: presentedAt,
+			Accepted:    cerr.Type == TypeNoError,
+			Credentials: []PresentedCredential{newPresentedCredential(body.CredentialID, &cred)},
+		}, cerr)
+	}
 	if cerr.Type != TypeNoError {
-		return cerr
+		return "", cerr
 	}
 	defer res.Close()
 
-	cred = common.VerifiableCredential{}
-	err = common.DecodeJSON(res, &cred)
+	//the issuer sends the credential as JSON, or as a JWT-VC
+	issued, err := common.DecodeCredential(res)
 	if err != nil {
 		common.LogChainError("error decoding verifiable credential", err)
-		return InternalError()
+		return "", InternalError()
 	}
 
-	creds := CredentialsMap{}
-	err = common.LoadJSONFromFile(VC_URI, &creds)
+	err = verifyReceivedCredential(issued, pres, DID)
 	if err != nil {
-		common.LogChainError("error loading verifiable credentials", err)
-		return InternalError()
+		return "", receivedCredentialError(err)
 	}
 
-	creds[cred.Issuer.DID] = cred
-	err = common.WriteJSONToFile(VC_URI, &creds)
+	id, err := Store.AddCredential(issued)
 	if err != nil {
-		common.LogChainError("error saving verifiable credentials", err)
-		return InternalError()
+		return "", storeError("error saving verifiable credential", err)
 	}
 
-	return NoError()
+	return id, NoError()
 }
@@ -0,0 +1,222 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+	"vcd/common"
+)
+
+// testIssuer issues a credential for every form it is sent, counting how many times each form was served.
+// Responses are held until a request from every worker has arrived, so the wallet saves their credentials at the same time.
+type testIssuer struct {
+	DID    string
+	signer common.Signer
+
+	mu      sync.Mutex
+	served  map[string]int
+	waiting int
+	release chan struct{}
+}
+
+func newTestIssuer(t *testing.T) *testIssuer {
+	t.Helper()
+
+	key, err := common.GenerateKey("ed25519")
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := common.NewSigner(key, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	DID, err := common.EncodeDIDKey(key.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &testIssuer{
+		DID:     DID,
+		signer:  signer,
+		served:  map[string]int{},
+		release: make(chan struct{}),
+	}
+}
+
+func (i *testIssuer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	cred := common.VerifiableCredential{}
+	err := common.DecodeJSON(req.Body, &cred)
+	if err != nil {
+		common.SendErrorResponse(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+
+	err = common.VerifyDIDSignature(common.AUTHENTICATION, time.Now(), &cred.Subject, &cred)
+	if err != nil {
+		common.SendErrorResponse(w, http.StatusUnauthorized, "Subject signature could not be verified.")
+		return
+	}
+
+	i.mu.Lock()
+	i.served[cred.Credentials["Number"]]++
+	release := i.release
+	i.waiting++
+	if i.waiting == CONCURRENT_WORKERS {
+		close(release)
+		i.release = make(chan struct{})
+		i.waiting = 0
+	}
+	i.mu.Unlock()
+
+	select {
+	case <-release:
+	case <-time.After(time.Second):
+	}
+
+	cred.ID, err = common.GenerateUUID()
+	if err != nil {
+		common.SendInternalErrorResponse(w)
+		return
+	}
+	cred.CredType = "Test Credential"
+	cred.SetValidityPeriod(time.Now(), time.Hour)
+	cred.Subject = common.Signature{DID: cred.Subject.DID}
+	cred.Issuer = common.Signature{DID: i.DID}
+
+	err = common.SignStructWithSigner(i.signer, &cred.Issuer, &cred)
+	if err != nil {
+		common.SendInternalErrorResponse(w)
+		return
+	}
+
+	common.SendJSONResponse(w, http.StatusOK, &cred)
+}
+
+// TestConcurrentPostIssue submits forms to an issuer from several goroutines at once,
+// and checks every form is served once and every issued credential is saved.
+func TestConcurrentPostIssue(t *testing.T) {
+	common.BlockchainDir = t.TempDir()
+
+	defaultStore := Store
+	defer func() {
+		Store = defaultStore
+	}()
+
+	for name, store := range newTestStores(t) {
+		store := store
+		t.Run(name, func(t *testing.T) {
+			holderKey, err := common.GenerateKey("ed25519")
+			if err != nil {
+				t.Fatal(err)
+			}
+			privateKey, err := common.EncodePrivateKey(holderKey)
+			if err != nil {
+				t.Fatal(err)
+			}
+			holderDID, err := common.EncodeDIDKey(holderKey.Public())
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = store.SetPrivateKey(privateKey, holderDID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			Store = store
+
+			issuer := newTestIssuer(t)
+			server := httptest.NewServer(issuer)
+			defer server.Close()
+
+			savePendingRequest(&common.PresentationRequest{
+				Type:       "iss:form",
+				ServiceURL: server.URL,
+				CredType:   "Test Credential",
+				Entity:     common.Signature{DID: issuer.DID},
+			})
+
+			ids := make(chan string, CONCURRENT_CREDENTIALS)
+			errs := make(chan error, CONCURRENT_CREDENTIALS)
+
+			jobs := make(chan int)
+			wg := sync.WaitGroup{}
+			for w := 0; w < CONCURRENT_WORKERS; w++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for i := range jobs {
+						body, err := json.Marshal(&IssuePostBody{
+							ServiceURL: server.URL,
+							Type:       "iss:form",
+							Fields:     map[string]string{"Number": fmt.Sprint(i)},
+						})
+						if err != nil {
+							errs <- err
+							continue
+						}
+
+						w := httptest.NewRecorder()
+						PostIssueHandler(w, httptest.NewRequest(http.MethodPost, "/issue", bytes.NewReader(body)))
+						if w.Code != http.StatusOK {
+							errs <- fmt.Errorf("form %d: status %d: %s", i, w.Code, w.Body.String())
+							continue
+						}
+
+						res := PostIssueResponse{}
+						err = json.Unmarshal(w.Body.Bytes(), &res)
+						if err != nil {
+							errs <- err
+							continue
+						}
+						ids <- res.ID
+					}
+				}()
+			}
+
+			for i := 0; i < CONCURRENT_CREDENTIALS; i++ {
+				jobs <- i
+			}
+			close(jobs)
+			wg.Wait()
+			close(ids)
+			close(errs)
+
+			for err := range errs {
+				t.Error(err)
+			}
+
+			for i := 0; i < CONCURRENT_CREDENTIALS; i++ {
+				if served := issuer.served[fmt.Sprint(i)]; served != 1 {
+					t.Errorf("form %d was served %d times", i, served)
+				}
+			}
+
+			creds, err := store.ListCredentials(CredentialFilter{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(creds) != CONCURRENT_CREDENTIALS {
+				t.Fatalf("expected %d credentials, found %d", CONCURRENT_CREDENTIALS, len(creds))
+			}
+
+			numbers := map[string]bool{}
+			for id := range ids {
+				cred, ok := creds[id]
+				if !ok {
+					t.Fatalf("credential %s was not saved", id)
+				}
+				if numbers[cred.Credentials["Number"]] {
+					t.Errorf("form %s was saved twice", cred.Credentials["Number"])
+				}
+				numbers[cred.Credentials["Number"]] = true
+			}
+			if len(numbers) != CONCURRENT_CREDENTIALS {
+				t.Errorf("expected a credential for each of %d forms, found %d", CONCURRENT_CREDENTIALS, len(numbers))
+			}
+		})
+	}
+}
@@ -0,0 +1,12 @@
+package handlers
+
+import (
+	"net/http"
+	"vcd/common"
+)
+
+func PostLockHandler(w http.ResponseWriter, _ *http.Request) {
+	Store.Lock()
+
+	common.SendSuccessResponse(w)
+}
@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"vcd/common"
+)
+
+type PostRecoverBody struct {
+	Shares []KeyShare `json:"shares"`
+}
+
+func PostRecoverHandler(w http.ResponseWriter, req *http.Request) {
+	body := PostRecoverBody{}
+
+	err := common.DecodeJSON(req.Body, &body)
+	if err != nil {
+		common.LogChainError("error decoding post recover body", err)
+		common.SendErrorResponse(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+
+	res, cerr := postRecover(&body)
+	if cerr.Type != TypeNoError {
+		sendCustomError(w, cerr)
+		return
+	}
+
+	common.SendJSONResponse(w, http.StatusOK, res)
+}
+
+func postRecover(body *PostRecoverBody) (*RecoveryResult, CustomError) {
+	//the recovered key is saved into the store, which must be unlocked
+	if Store.IsLocked() {
+		return nil, LockedError()
+	}
+
+	res, err := recoverHolderKey(body.Shares)
+	if errors.Is(err, ErrInsufficientShares) {
+		common.LogChainError("error recovering holder key", err)
+		return nil, ClientError("Not enough key shares to recover the key.")
+	}
+	if errors.Is(err, ErrInvalidShares) {
+		common.LogChainError("error recovering holder key", err)
+		return nil, ClientError("The key shares do not recover the wallet's key.")
+	}
+	if err != nil {
+		return nil, storeError("error recovering holder key", err)
+	}
+
+	return res, NoError()
+}
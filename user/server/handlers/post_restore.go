@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"vcd/common"
+)
+
+type PostRestoreBody struct {
+	Passphrase string            `json:"passphrase"`
+	Backup     encryptedEnvelope `json:"backup"`
+}
+
+func PostRestoreHandler(w http.ResponseWriter, req *http.Request) {
+	body := PostRestoreBody{}
+
+	err := common.DecodeJSON(req.Body, &body)
+	if err != nil {
+		common.LogChainError("error decoding post restore body", err)
+		common.SendErrorResponse(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+
+	cerr := postRestore(&body)
+	if cerr.Type != TypeNoError {
+		sendCustomError(w, cerr)
+		return
+	}
+
+	common.SendSuccessResponse(w)
+}
+
+func postRestore(body *PostRestoreBody) CustomError {
+	if body.Passphrase == "" {
+		return ClientError("A passphrase is required.")
+	}
+
+	//restoring into a locked wallet would fail after the checks below, so fail early
+	if Store.IsLocked() {
+		return LockedError()
+	}
+
+	bundle, err := openBackup(&body.Backup, body.Passphrase)
+	if errors.Is(err, ErrWrongPassphrase) {
+		log.Println("wallet restore attempted with the wrong passphrase or a modified backup")
+		return ClientError("Incorrect passphrase, or the backup has been modified.")
+	}
+	if err != nil {
+		common.LogChainError("error opening wallet backup", err)
+		return ClientError("Invalid wallet backup.")
+	}
+
+	err = verifyBundle(bundle)
+	if err != nil {
+		common.LogChainError("error verifying wallet backup", err)
+		return ClientError("Wallet backup could not be verified.")
+	}
+
+	err = restoreBundle(bundle)
+	if err != nil {
+		return storeError("error restoring wallet backup", err)
+	}
+
+	return NoError()
+}
@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"net/http"
+	"vcd/common"
+)
+
+type PostSharesBody struct {
+	Shares    int `json:"shares"`
+	Threshold int `json:"threshold"`
+}
+
+func PostSharesHandler(w http.ResponseWriter, req *http.Request) {
+	body := PostSharesBody{}
+
+	err := common.DecodeJSON(req.Body, &body)
+	if err != nil {
+		common.LogChainError("error decoding post shares body", err)
+		common.SendErrorResponse(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+
+	if body.Threshold < 2 || body.Shares < body.Threshold || body.Shares > 255 {
+		common.SendErrorResponse(w, http.StatusBadRequest, "The threshold must be at least 2, and the number of shares between the threshold and 255.")
+		return
+	}
+
+	shares, err := splitHolderKey(body.Shares, body.Threshold)
+	if err != nil {
+		sendCustomError(w, storeError("error splitting holder key", err))
+		return
+	}
+
+	common.SendJSONResponse(w, http.StatusOK, shares)
+}
@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"vcd/common"
+)
+
+type PostUnlockBody struct {
+	Passphrase string `json:"passphrase"`
+}
+
+func PostUnlockHandler(w http.ResponseWriter, req *http.Request) {
+	body := PostUnlockBody{}
+
+	err := common.DecodeJSON(req.Body, &body)
+	if err != nil {
+		common.LogChainError("error decoding post unlock body", err)
+		common.SendErrorResponse(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+
+	cerr := postUnlock(&body)
+	if cerr.Type != TypeNoError {
+		sendCustomError(w, cerr)
+		return
+	}
+
+	common.SendSuccessResponse(w)
+}
+
+func postUnlock(body *PostUnlockBody) CustomError {
+	if body.Passphrase == "" {
+		return ClientError("A passphrase is required.")
+	}
+
+	err := Store.Unlock(body.Passphrase)
+	if errors.Is(err, ErrWrongPassphrase) {
+		log.Println("wallet unlock attempted with the wrong passphrase")
+		return ClientError("Incorrect passphrase.")
+	}
+	if err != nil {
+		common.LogChainError("error unlocking wallet", err)
+		return InternalError()
+	}
+
+	return NoError()
+}
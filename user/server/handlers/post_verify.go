@@ -1,16 +1,16 @@
 package handlers
 
 import (
+	"errors"
 	"log"
 	"net/http"
+	"time"
 	"vcd/common"
 )
 
-const PRIVATE_KEY_URI = "wallet/private.key"
-
 type PostVerifyBody struct {
-	ServiceURL   string `json:"service_url"`
-	CredentialID string `json:"credential_id"`
+	ServiceURL    string   `json:"service_url"`
+	CredentialIDs []string `json:"credential_ids"`
 }
 
 func PostVerifyHandler(w http.ResponseWriter, req *http.Request) {
@@ -24,12 +24,8 @@ func PostVerifyHandler(w http.ResponseWriter, req *http.Request) {
 	}
 
 	cerr := postVerify(&body)
-	if cerr.Type == TypeClientError {
-		common.SendErrorResponse(w, http.StatusBadRequest, cerr.Message)
-		return
-	}
-	if cerr.Type == TypeInternalError {
-		common.SendInternalErrorResponse(w)
+	if cerr.Type != TypeNoError {
+		sendCustomError(w, cerr)
 		return
 	}
 
@@ -37,31 +33,113 @@ func PostVerifyHandler(w http.ResponseWriter, req *http.Request) {
 }
 
 func postVerify(body *PostVerifyBody) CustomError {
-	creds, err := loadVerifiableCredentials()
+	pres, ok := takePendingRequest(body.ServiceURL)
+	if !ok {
+		log.Println("no pending presentation request for", body.ServiceURL)
+		return ClientError("Request has expired, please query the service again.")
+	}
+
+	selected := []common.VerifiableCredential{}
+	for _, id := range body.CredentialIDs {
+		cred, err := Store.GetCredential(id)
+		if errors.Is(err, ErrCredentialNotFound) {
+			log.Println("credential with id", id, "no found")
+			return ClientError("No credential found for ID.")
+		}
+		if err != nil {
+			return storeError("error loading verifiable credential", err)
+		}
+		selected = append(selected, *cred)
+	}
+
+	selected, err := discloseRequiredFields(pres.Requirements, selected)
 	if err != nil {
-		common.LogChainError("error loading verifiable credentials", err)
-		return InternalError()
+		common.LogChainError("error disclosing required fields", err)
+		return ClientError("Selected credentials do not satisfy the request.")
 	}
 
-	cred, ok := (*creds)[body.CredentialID]
-	if !ok {
-		log.Println("credential with id", body.CredentialID, "no found")
-		return ClientError("No credential found for ID.")
+	DID, err := loadHolderDID()
+	if err != nil {
+		return storeError("error loading holder DID", err)
+	}
+
+	vp := common.VerifiablePresentation{
+		Credentials: selected,
+		Nonce:       pres.Nonce,
+		Audience:    pres.Audience,
+		Timestamp:   time.Now().UTC(),
+		Holder: common.Signature{
+			DID: DID,
+		},
+	}
+
+	signer, err := Store.LoadSigner()
+	if err != nil {
+		return storeError("error loading private key", err)
 	}
 
-	err = common.SignStruct(PRIVATE_KEY_URI, &cred.Subject, &cred)
+	err = common.SignStructWithSigner(signer, &vp.Holder, &vp)
 	if err != nil {
-		common.LogChainError("error signing credential", err)
+		common.LogChainError("error signing presentation", err)
 		return InternalError()
 	}
 
-	_, cerr, err := sendRequest(http.MethodPost, body.ServiceURL, &cred)
+	_, cerr, err := sendRequest(http.MethodPost, body.ServiceURL, &vp)
 	if err != nil {
 		log.Println(err)
 	}
+
+	recordPresentation(pres, newPresentationRecord(body, &vp, cerr.Type == TypeNoError), cerr)
+
 	if cerr.Type != TypeNoError {
 		return cerr
 	}
 
 	return NoError()
 }
+
+// recordPresentation adds a presentation that was sent to the consent log, and to the history if the store keeps one.
+// The presentation has already been sent, so a failure to record it is logged but not reported to the user.
+func recordPresentation(pres *common.PresentationRequest, record *PresentationRecord, cerr CustomError) {
+	err := Store.AppendConsent(newConsentEntry(pres, record, cerr))
+	if err != nil {
+		common.LogChainError("error appending to consent log", err)
+	}
+
+	if history, ok := Store.(PresentationHistory); ok {
+		err = history.RecordPresentation(record)
+		if err != nil {
+			common.LogChainError("error recording presentation", err)
+		}
+	}
+}
+
+// newPresentationRecord describes the presentation for the wallet's history.
+// The disclosed credentials are matched to the selected wallet IDs by their issuer signatures.
+func newPresentationRecord(body *PostVerifyBody, vp *common.VerifiablePresentation, accepted bool) *PresentationRecord {
+	record := PresentationRecord{
+		ServiceURL:  body.ServiceURL,
+		Audience:    vp.Audience,
+		Nonce:       vp.Nonce,
+		PresentedAt: vp.Timestamp,
+		Accepted:    accepted,
+		Credentials: []PresentedCredential{},
+	}
+
+	for i := range vp.Credentials {
+		cred := &vp.Credentials[i]
+
+		id := cred.ID
+		for _, selectedID := range body.CredentialIDs {
+			selected, err := Store.GetCredential(selectedID)
+			if err == nil && selected.Issuer.Signature == cred.Issuer.Signature && selected.JWT == cred.JWT {
+				id = selectedID
+				break
+			}
+		}
+
+		record.Credentials = append(record.Credentials, newPresentedCredential(id, cred))
+	}
+
+	return &record
+}
@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"errors"
+	"vcd/common"
+	"vcd/verifier"
+)
+
+var ErrUnexpectedIssuer = errors.New("credential was not issued by the queried service")
+var ErrUnexpectedCredType = errors.New("credential type does not match the request")
+var ErrUnexpectedSubject = errors.New("credential subject is not this wallet")
+
+// verifyReceivedCredential checks a credential returned by an issuer before it is saved:
+// that it was issued by the entity that signed the request, has the requested type, is bound to the holder,
+// and that its issuer signature, disclosures, validity period and revocation status verify.
+func verifyReceivedCredential(cred *common.VerifiableCredential, pres *common.PresentationRequest, holderDID string) error {
+	if cred.Issuer.DID != pres.Entity.DID {
+		return ErrUnexpectedIssuer
+	}
+	if cred.CredType != pres.CredType {
+		return ErrUnexpectedCredType
+	}
+	if cred.Subject.DID != holderDID {
+		return ErrUnexpectedSubject
+	}
+
+	return verifier.VerifyCredential(cred)
+}
+
+// receivedCredentialError rejects a credential that failed verifyReceivedCredential.
+func receivedCredentialError(err error) CustomError {
+	if errors.Is(err, ErrUnexpectedIssuer) || errors.Is(err, ErrUnexpectedCredType) || errors.Is(err, ErrUnexpectedSubject) {
+		common.LogChainError("received credential rejected", err)
+		return ClientError("Received credential was rejected: " + err.Error())
+	}
+
+	return verificationError(err)
+}
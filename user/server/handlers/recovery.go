@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"time"
+	"vcd/common"
+)
+
+var ErrInsufficientShares = errors.New("not enough key shares")
+var ErrInvalidShares = errors.New("key shares do not recover the holder key")
+
+// KeyShare is one of the shares the holder key is split into, to be given to a guardian.
+// Shares from the same split have the same set ID.
+type KeyShare struct {
+	Version   int    `json:"version"`
+	SetID     string `json:"set_id"`
+	DID       string `json:"did"`
+	Threshold int    `json:"threshold"`
+	Shares    int    `json:"shares"`
+	Index     byte   `json:"index"`
+	Value     []byte `json:"value"`
+}
+
+// splitHolderKey splits the wallet's private key into n shares, any threshold of which recover it.
+func splitHolderKey(n int, threshold int) ([]KeyShare, error) {
+	DID, err := loadHolderDID()
+	if err != nil {
+		return nil, common.ChainError("error loading holder DID", err)
+	}
+
+	privateKey, err := Store.LoadPrivateKey()
+	if err != nil {
+		return nil, common.ChainError("error loading private key", err)
+	}
+
+	secretShares, err := common.SplitSecret(privateKey, n, threshold)
+	if err != nil {
+		return nil, common.ChainError("error splitting private key", err)
+	}
+
+	setID, err := common.GenerateUUID()
+	if err != nil {
+		return nil, common.ChainError("error generating share set ID", err)
+	}
+
+	shares := []KeyShare{}
+	for _, share := range secretShares {
+		shares = append(shares, KeyShare{
+			Version:   1,
+			SetID:     setID,
+			DID:       DID,
+			Threshold: threshold,
+			Shares:    n,
+			Index:     share.X,
+			Value:     share.Y,
+		})
+	}
+
+	return shares, nil
+}
+
+// combineKeyShares reconstructs the private key from the shares and checks it belongs to their DID.
+func combineKeyShares(shares []KeyShare) ([]byte, error) {
+	if len(shares) == 0 {
+		return nil, ErrInsufficientShares
+	}
+
+	first := shares[0]
+	secretShares := []common.SecretShare{}
+	for _, share := range shares {
+		if share.SetID != first.SetID || share.DID != first.DID || share.Threshold != first.Threshold {
+			return nil, fmt.Errorf("%w: shares are from different splits", ErrInvalidShares)
+		}
+
+		secretShares = append(secretShares, common.SecretShare{
+			X: share.Index,
+			Y: share.Value,
+		})
+	}
+
+	if len(shares) < first.Threshold {
+		return nil, fmt.Errorf("%w: %d of %d required", ErrInsufficientShares, len(shares), first.Threshold)
+	}
+
+	privateKey, err := common.CombineShares(secretShares)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidShares, err)
+	}
+
+	signer, err := common.ParseSigner(privateKey, "")
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidShares, err)
+	}
+
+	err = verifyHolderKey(signer, first.DID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidShares, err)
+	}
+
+	return privateKey, nil
+}
+
+type RecoveryResult struct {
+	DID string `json:"did"`
+
+	//wallet IDs of the credentials that are held by the DID and have valid issuer signatures, and of those that do not
+	ValidCredentials   []string `json:"valid_credentials"`
+	InvalidCredentials []string `json:"invalid_credentials"`
+}
+
+// recoverHolderKey installs the key recovered from the shares in the wallet, then re-validates the wallet's credentials against it.
+func recoverHolderKey(shares []KeyShare) (*RecoveryResult, error) {
+	privateKey, err := combineKeyShares(shares)
+	if err != nil {
+		return nil, err
+	}
+	DID := shares[0].DID
+
+	err = Store.SetPrivateKey(privateKey, DID)
+	if err != nil {
+		return nil, common.ChainError("error saving recovered private key", err)
+	}
+
+	creds, err := Store.ListCredentials(CredentialFilter{Now: time.Now()})
+	if err != nil {
+		return nil, common.ChainError("error loading verifiable credentials", err)
+	}
+
+	res := RecoveryResult{
+		DID:                DID,
+		ValidCredentials:   []string{},
+		InvalidCredentials: []string{},
+	}
+
+	for id, cred := range creds {
+		err = verifyHeldCredential(&cred, DID)
+		if err != nil {
+			common.LogChainError("credential "+id+" is not valid for the recovered key", err)
+			res.InvalidCredentials = append(res.InvalidCredentials, id)
+			continue
+		}
+		res.ValidCredentials = append(res.ValidCredentials, id)
+	}
+
+	return &res, nil
+}
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"sync"
+	"vcd/common"
+)
+
+// presentation requests that have been verified by a query, keyed by service url
+var pendingRequests = struct {
+	sync.Mutex
+	requests map[string]common.PresentationRequest
+}{
+	requests: map[string]common.PresentationRequest{},
+}
+
+func savePendingRequest(pres *common.PresentationRequest) {
+	pendingRequests.Lock()
+	defer pendingRequests.Unlock()
+
+	pendingRequests.requests[pres.ServiceURL] = *pres
+}
+
+func getPendingRequest(serviceURL string) (*common.PresentationRequest, bool) {
+	pendingRequests.Lock()
+	defer pendingRequests.Unlock()
+
+	pres, ok := pendingRequests.requests[serviceURL]
+	if !ok {
+		return nil, false
+	}
+
+	return &pres, true
+}
+
+func takePendingRequest(serviceURL string) (*common.PresentationRequest, bool) {
+	pendingRequests.Lock()
+	defer pendingRequests.Unlock()
+
+	pres, ok := pendingRequests.requests[serviceURL]
+	if !ok {
+		return nil, false
+	}
+	delete(pendingRequests.requests, serviceURL)
+
+	return &pres, true
+}
+
+// discloseRequiredFields matches the credentials to the requirements and reveals only the fields each requirement lists.
+func discloseRequiredFields(reqs []common.CredentialRequirement, creds []common.VerifiableCredential) ([]common.VerifiableCredential, error) {
+	matched, err := common.MatchRequirements(reqs, creds)
+	if err != nil {
+		return nil, common.ChainError("error matching credentials to requirements", err)
+	}
+
+	for i, req := range reqs {
+		matched[i], err = matched[i].Disclose(req.Fields)
+		if err != nil {
+			return nil, common.ChainError("error disclosing credential fields", err)
+		}
+	}
+
+	return matched, nil
+}
@@ -0,0 +1,769 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+	"vcd/common"
+
+	_ "modernc.org/sqlite"
+)
+
+const SQLITE_WALLET_URI = "wallet/wallet.db"
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS credentials (
+	id TEXT PRIMARY KEY,
+	cred_type TEXT NOT NULL,
+	issuer TEXT NOT NULL,
+	subject TEXT NOT NULL,
+	issued_at INTEGER,
+	expires_at INTEGER,
+	added_at INTEGER NOT NULL,
+	data TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS credentials_issuer ON credentials (issuer);
+CREATE INDEX IF NOT EXISTS credentials_cred_type ON credentials (cred_type);
+
+CREATE TABLE IF NOT EXISTS credential_fields (
+	credential_id TEXT NOT NULL REFERENCES credentials (id) ON DELETE CASCADE,
+	name TEXT NOT NULL,
+	value TEXT NOT NULL,
+	PRIMARY KEY (credential_id, name)
+);
+CREATE INDEX IF NOT EXISTS credential_fields_name_value ON credential_fields (name, value);
+
+CREATE TABLE IF NOT EXISTS presentations (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	service_url TEXT NOT NULL,
+	audience TEXT NOT NULL,
+	nonce TEXT NOT NULL,
+	presented_at INTEGER NOT NULL,
+	accepted INTEGER NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS presented_credentials (
+	presentation_id INTEGER NOT NULL REFERENCES presentations (id) ON DELETE CASCADE,
+	credential_id TEXT NOT NULL,
+	cred_type TEXT NOT NULL,
+	issuer TEXT NOT NULL,
+	fields TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS presented_credentials_credential ON presented_credentials (credential_id);
+
+CREATE TABLE IF NOT EXISTS consent_log (
+	idx INTEGER PRIMARY KEY,
+	hash TEXT NOT NULL,
+	entry TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS consent_head (
+	id INTEGER PRIMARY KEY CHECK (id = 1),
+	mac TEXT NOT NULL
+);
+`
+
+// sqlQuerier is implemented by both the database and its transactions.
+type sqlQuerier interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// SQLiteStore keeps the credentials, their issuance metadata, a history of presentations and the consent log in an SQLite database,
+// so credentials can be searched without loading the whole wallet. Like PlainFileStore it is not encrypted,
+// and the private key is read from an unencrypted PEM file.
+type SQLiteStore struct {
+	db         *sql.DB
+	privateKey string
+	didURI     string
+}
+
+// NewSQLiteStore opens the database, creating it if needed.
+// A new database is filled with the credentials and consent log from the plaintext files if there are any.
+// The private key and DID files are used where they are.
+func NewSQLiteStore(uri string, paths WalletPaths) (*SQLiteStore, error) {
+	db, err := sql.Open("sqlite", uri+"?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)")
+	if err != nil {
+		return nil, common.ChainError("error opening database", err)
+	}
+
+	//a single connection serializes writes to the wallet
+	db.SetMaxOpenConns(1)
+
+	_, err = db.Exec(sqliteSchema)
+	if err != nil {
+		db.Close()
+		return nil, common.ChainError("error creating database schema", err)
+	}
+
+	s := &SQLiteStore{
+		db:         db,
+		privateKey: paths.PrivateKey,
+		didURI:     paths.DID,
+	}
+
+	err = s.importLegacyCredentials(paths.Credentials)
+	if err != nil {
+		db.Close()
+		return nil, common.ChainError("error importing plaintext credentials", err)
+	}
+
+	err = s.importLegacyConsentLog(paths.ConsentLog)
+	if err != nil {
+		db.Close()
+		return nil, common.ChainError("error importing plaintext consent log", err)
+	}
+
+	return s, nil
+}
+
+func (s *SQLiteStore) Unlock(_ string) error {
+	return nil
+}
+
+func (s *SQLiteStore) Lock() {}
+
+func (s *SQLiteStore) IsLocked() bool {
+	return false
+}
+
+func (s *SQLiteStore) ListCredentials(filter CredentialFilter) (CredentialsMap, error) {
+	query := "SELECT c.id, c.data FROM credentials c WHERE 1 = 1"
+	args := []interface{}{}
+
+	if filter.Issuer != "" {
+		query += " AND c.issuer = ?"
+		args = append(args, filter.Issuer)
+	}
+	if filter.CredType != "" {
+		query += " AND c.cred_type = ?"
+		args = append(args, filter.CredType)
+	}
+
+	if filter.Field != "" {
+		query += " AND EXISTS (SELECT 1 FROM credential_fields f WHERE f.credential_id = c.id AND f.name = ?"
+		args = append(args, filter.Field)
+
+		if filter.FieldValue != "" {
+			query += " AND f.value = ?"
+			args = append(args, filter.FieldValue)
+		}
+		query += ")"
+	}
+
+	if filter.Expired != nil {
+		if *filter.Expired {
+			query += " AND c.expires_at IS NOT NULL AND c.expires_at <= ?"
+		} else {
+			query += " AND (c.expires_at IS NULL OR c.expires_at > ?)"
+		}
+		args = append(args, filter.Now.UnixNano())
+	}
+	if filter.ExpiresBefore != nil {
+		query += " AND c.expires_at IS NOT NULL AND c.expires_at < ?"
+		args = append(args, filter.ExpiresBefore.UnixNano())
+	}
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, common.ChainError("error querying credentials", err)
+	}
+	defer rows.Close()
+
+	creds := CredentialsMap{}
+	for rows.Next() {
+		var id, data string
+		err = rows.Scan(&id, &data)
+		if err != nil {
+			return nil, common.ChainError("error scanning credential", err)
+		}
+
+		cred := common.VerifiableCredential{}
+		err = json.Unmarshal([]byte(data), &cred)
+		if err != nil {
+			return nil, common.ChainError("error decoding credential "+id, err)
+		}
+
+		creds[id] = cred
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, common.ChainError("error reading credentials", err)
+	}
+
+	return creds, nil
+}
+
+func (s *SQLiteStore) GetCredential(id string) (*common.VerifiableCredential, error) {
+	var data string
+	err := s.db.QueryRow("SELECT data FROM credentials WHERE id = ?", id).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrCredentialNotFound
+	}
+	if err != nil {
+		return nil, common.ChainError("error querying credential", err)
+	}
+
+	cred := common.VerifiableCredential{}
+	err = json.Unmarshal([]byte(data), &cred)
+	if err != nil {
+		return nil, common.ChainError("error decoding credential", err)
+	}
+
+	return &cred, nil
+}
+
+func (s *SQLiteStore) AddCredential(cred *common.VerifiableCredential) (string, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return "", common.ChainError("error starting transaction", err)
+	}
+	defer tx.Rollback()
+
+	id, err := insertCredential(tx, "", cred, time.Now())
+	if err != nil {
+		return "", err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return "", common.ChainError("error committing transaction", err)
+	}
+
+	return id, nil
+}
+
+func (s *SQLiteStore) LoadSigner() (common.Signer, error) {
+	return common.LoadSignerFromFile(s.privateKey, "")
+}
+
+func (s *SQLiteStore) LoadPrivateKey() ([]byte, error) {
+	return common.LoadKeyFromFile(s.privateKey)
+}
+
+func (s *SQLiteStore) LoadDID() (string, error) {
+	return readDIDFile(s.didURI)
+}
+
+// Restore replaces the credentials, consent log and presentation history in a transaction.
+// The private key and DID files are replaced just before the transaction commits, and put back if the commit fails.
+func (s *SQLiteStore) Restore(bundle *WalletBundle) error {
+	didUpdate, err := didFileUpdate(s.didURI, bundle.DID, []byte(bundle.PrivateKey))
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return common.ChainError("error starting transaction", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec("DELETE FROM credentials")
+	if err != nil {
+		return common.ChainError("error removing credentials", err)
+	}
+
+	now := time.Now()
+	for key, cred := range bundle.Credentials {
+		cred := cred
+		_, err = insertCredential(tx, key, &cred, now)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = replaceConsentLog(tx, &bundle.ConsentLog)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("DELETE FROM presented_credentials")
+	if err != nil {
+		return common.ChainError("error removing presented credentials", err)
+	}
+	_, err = tx.Exec("DELETE FROM presentations")
+	if err != nil {
+		return common.ChainError("error removing presentations", err)
+	}
+
+	//insert the oldest presentations first so the history keeps its order
+	presentations := append([]PresentationRecord{}, bundle.Presentations...)
+	sort.SliceStable(presentations, func(i, j int) bool {
+		return presentations[i].PresentedAt.Before(presentations[j].PresentedAt)
+	})
+	for i := range presentations {
+		err = insertPresentation(tx, &presentations[i])
+		if err != nil {
+			return err
+		}
+	}
+
+	rollback, err := commitWalletFiles([]walletFileUpdate{
+		{uri: s.privateKey, data: []byte(bundle.PrivateKey), backup: true},
+		didUpdate,
+	})
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		rollback()
+		return common.ChainError("error committing transaction", err)
+	}
+
+	return nil
+}
+
+// SetPrivateKey replaces the private key and DID files, and re-authenticates the consent log in a transaction
+// that commits once the files are replaced.
+func (s *SQLiteStore) SetPrivateKey(privateKey []byte, DID string) error {
+	didUpdate, err := didFileUpdate(s.didURI, DID, privateKey)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return common.ChainError("error starting transaction", err)
+	}
+	defer tx.Rollback()
+
+	consentLog, err := loadConsentLog(tx)
+	if err != nil {
+		return err
+	}
+
+	//the old key cannot be read if it was lost, then the log is kept as it is
+	oldPrivateKey, _ := common.LoadKeyFromFile(s.privateKey)
+	err = consentLog.rekey(oldPrivateKey, privateKey)
+	if err != nil {
+		return err
+	}
+
+	err = replaceConsentLog(tx, consentLog)
+	if err != nil {
+		return err
+	}
+
+	rollback, err := commitWalletFiles([]walletFileUpdate{
+		{uri: s.privateKey, data: privateKey, backup: true},
+		didUpdate,
+	})
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		rollback()
+		return common.ChainError("error committing transaction", err)
+	}
+
+	return nil
+}
+
+func (s *SQLiteStore) AppendConsent(entry *ConsentEntry) error {
+	privateKey, err := s.LoadPrivateKey()
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return common.ChainError("error starting transaction", err)
+	}
+	defer tx.Rollback()
+
+	consentLog, err := loadConsentLog(tx)
+	if err != nil {
+		return err
+	}
+
+	err = consentLog.check(privateKey)
+	if err != nil {
+		return err
+	}
+
+	err = consentLog.append(entry, privateKey)
+	if err != nil {
+		return err
+	}
+
+	err = insertConsentEntry(tx, entry)
+	if err != nil {
+		return err
+	}
+
+	err = setConsentHead(tx, consentLog.HeadMAC)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return common.ChainError("error committing transaction", err)
+	}
+
+	return nil
+}
+
+func (s *SQLiteStore) ListConsent() (*ConsentLog, error) {
+	return loadConsentLog(s.db)
+}
+
+func (s *SQLiteStore) RecordPresentation(record *PresentationRecord) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return common.ChainError("error starting transaction", err)
+	}
+	defer tx.Rollback()
+
+	err = insertPresentation(tx, record)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return common.ChainError("error committing transaction", err)
+	}
+
+	return nil
+}
+
+func (s *SQLiteStore) ListPresentations(credentialID string) ([]PresentationRecord, error) {
+	query := "SELECT id, service_url, audience, nonce, presented_at, accepted FROM presentations"
+	args := []interface{}{}
+
+	if credentialID != "" {
+		query += " WHERE id IN (SELECT presentation_id FROM presented_credentials WHERE credential_id = ?)"
+		args = append(args, credentialID)
+	}
+	query += " ORDER BY id DESC"
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, common.ChainError("error querying presentations", err)
+	}
+
+	records := []PresentationRecord{}
+	for rows.Next() {
+		record := PresentationRecord{}
+		var presentedAt int64
+
+		err = rows.Scan(&record.ID, &record.ServiceURL, &record.Audience, &record.Nonce, &presentedAt, &record.Accepted)
+		if err != nil {
+			rows.Close()
+			return nil, common.ChainError("error scanning presentation", err)
+		}
+
+		record.PresentedAt = time.Unix(0, presentedAt).UTC()
+		records = append(records, record)
+	}
+	rows.Close()
+
+	err = rows.Err()
+	if err != nil {
+		return nil, common.ChainError("error reading presentations", err)
+	}
+
+	//the credentials are loaded once the presentation rows are closed, as the store only has one connection
+	for i := range records {
+		records[i].Credentials, err = s.listPresentedCredentials(records[i].ID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return records, nil
+}
+
+func (s *SQLiteStore) listPresentedCredentials(presentationID int64) ([]PresentedCredential, error) {
+	rows, err := s.db.Query(
+		"SELECT credential_id, cred_type, issuer, fields FROM presented_credentials WHERE presentation_id = ? ORDER BY rowid",
+		presentationID,
+	)
+	if err != nil {
+		return nil, common.ChainError("error querying presented credentials", err)
+	}
+	defer rows.Close()
+
+	creds := []PresentedCredential{}
+	for rows.Next() {
+		cred := PresentedCredential{}
+		var fields string
+
+		err = rows.Scan(&cred.ID, &cred.CredType, &cred.Issuer, &fields)
+		if err != nil {
+			return nil, common.ChainError("error scanning presented credential", err)
+		}
+
+		err = json.Unmarshal([]byte(fields), &cred.Fields)
+		if err != nil {
+			return nil, common.ChainError("error decoding disclosed fields", err)
+		}
+
+		creds = append(creds, cred)
+	}
+
+	return creds, rows.Err()
+}
+
+// importLegacyCredentials copies the plaintext credentials into the database if it has no credentials yet.
+func (s *SQLiteStore) importLegacyCredentials(uri string) error {
+	var count int
+	err := s.db.QueryRow("SELECT COUNT(*) FROM credentials").Scan(&count)
+	if err != nil {
+		return common.ChainError("error counting credentials", err)
+	}
+	if count > 0 {
+		return nil
+	}
+
+	creds := CredentialsMap{}
+	err = loadWalletFile(uri, &creds)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return common.ChainError("error starting transaction", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	for key, cred := range creds.rekeyed() {
+		cred := cred
+		_, err = insertCredential(tx, key, &cred, now)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return common.ChainError("error committing transaction", err)
+	}
+
+	return nil
+}
+
+// importLegacyConsentLog copies the plaintext consent log into the database if its log is empty.
+// The entries are copied as they are, so a broken chain is still detected.
+func (s *SQLiteStore) importLegacyConsentLog(uri string) error {
+	var count int
+	err := s.db.QueryRow("SELECT COUNT(*) FROM consent_log").Scan(&count)
+	if err != nil {
+		return common.ChainError("error counting consent entries", err)
+	}
+	if count > 0 {
+		return nil
+	}
+
+	consentLog := ConsentLog{}
+	err = loadWalletFile(uri, &consentLog)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return common.ChainError("error starting transaction", err)
+	}
+	defer tx.Rollback()
+
+	err = replaceConsentLog(tx, &consentLog)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return common.ChainError("error committing transaction", err)
+	}
+
+	return nil
+}
+
+// loadConsentLog reads the consent log and its head MAC.
+func loadConsentLog(q sqlQuerier) (*ConsentLog, error) {
+	consentLog := ConsentLog{Entries: []ConsentEntry{}}
+
+	err := q.QueryRow("SELECT mac FROM consent_head WHERE id = 1").Scan(&consentLog.HeadMAC)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, common.ChainError("error querying consent log head", err)
+	}
+
+	rows, err := q.Query("SELECT idx, entry FROM consent_log ORDER BY idx")
+	if err != nil {
+		return nil, common.ChainError("error querying consent log", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var idx int64
+		var data string
+		err = rows.Scan(&idx, &data)
+		if err != nil {
+			return nil, common.ChainError("error scanning consent entry", err)
+		}
+
+		entry := ConsentEntry{}
+		err = json.Unmarshal([]byte(data), &entry)
+		if err != nil {
+			return nil, common.ChainError("error decoding consent entry "+strconv.FormatInt(idx, 10), err)
+		}
+
+		consentLog.Entries = append(consentLog.Entries, entry)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, common.ChainError("error reading consent log", err)
+	}
+
+	return &consentLog, nil
+}
+
+// replaceConsentLog replaces the entries and head MAC of the consent log with the log's, copying them as they are.
+func replaceConsentLog(tx *sql.Tx, consentLog *ConsentLog) error {
+	_, err := tx.Exec("DELETE FROM consent_log")
+	if err != nil {
+		return common.ChainError("error removing consent log", err)
+	}
+
+	for i := range consentLog.Entries {
+		err = insertConsentEntry(tx, &consentLog.Entries[i])
+		if err != nil {
+			return err
+		}
+	}
+
+	return setConsentHead(tx, consentLog.HeadMAC)
+}
+
+func setConsentHead(tx *sql.Tx, mac string) error {
+	_, err := tx.Exec("DELETE FROM consent_head")
+	if err != nil {
+		return common.ChainError("error removing consent log head", err)
+	}
+
+	if mac == "" {
+		return nil
+	}
+
+	_, err = tx.Exec("INSERT INTO consent_head (id, mac) VALUES (1, ?)", mac)
+	if err != nil {
+		return common.ChainError("error inserting consent log head", err)
+	}
+
+	return nil
+}
+
+// insertPresentation adds the presentation to the history, setting its ID.
+func insertPresentation(tx *sql.Tx, record *PresentationRecord) error {
+	res, err := tx.Exec(
+		"INSERT INTO presentations (service_url, audience, nonce, presented_at, accepted) VALUES (?, ?, ?, ?, ?)",
+		record.ServiceURL, record.Audience, record.Nonce, record.PresentedAt.UnixNano(), record.Accepted,
+	)
+	if err != nil {
+		return common.ChainError("error inserting presentation", err)
+	}
+
+	record.ID, err = res.LastInsertId()
+	if err != nil {
+		return common.ChainError("error getting presentation id", err)
+	}
+
+	for _, cred := range record.Credentials {
+		fields, err := json.Marshal(cred.Fields)
+		if err != nil {
+			return common.ChainError("error encoding disclosed fields", err)
+		}
+
+		_, err = tx.Exec(
+			"INSERT INTO presented_credentials (presentation_id, credential_id, cred_type, issuer, fields) VALUES (?, ?, ?, ?, ?)",
+			record.ID, cred.ID, cred.CredType, cred.Issuer, string(fields),
+		)
+		if err != nil {
+			return common.ChainError("error inserting presented credential", err)
+		}
+	}
+
+	return nil
+}
+
+func insertConsentEntry(tx *sql.Tx, entry *ConsentEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return common.ChainError("error encoding consent entry", err)
+	}
+
+	_, err = tx.Exec("INSERT INTO consent_log (idx, hash, entry) VALUES (?, ?, ?)", entry.Index, entry.Hash, string(data))
+	if err != nil {
+		return common.ChainError("error inserting consent entry", err)
+	}
+
+	return nil
+}
+
+// insertCredential stores the credential under the key, or its ID if the key is empty,
+// replacing any credential with the same key. Credentials without an ID are given a random key.
+func insertCredential(tx *sql.Tx, key string, cred *common.VerifiableCredential, addedAt time.Time) (string, error) {
+	if key == "" {
+		var err error
+		key, err = credentialKey(cred)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	data, err := json.Marshal(cred)
+	if err != nil {
+		return "", common.ChainError("error encoding credential", err)
+	}
+
+	_, err = tx.Exec("DELETE FROM credentials WHERE id = ?", key)
+	if err != nil {
+		return "", common.ChainError("error removing replaced credential", err)
+	}
+
+	_, err = tx.Exec(
+		"INSERT INTO credentials (id, cred_type, issuer, subject, issued_at, expires_at, added_at, data) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		key, cred.CredType, cred.Issuer.DID, cred.Subject.DID, nullableTime(cred.IssuedAt), nullableTime(cred.ExpiresAt), addedAt.UnixNano(), string(data),
+	)
+	if err != nil {
+		return "", common.ChainError("error inserting credential", err)
+	}
+
+	for name, value := range cred.Credentials {
+		_, err = tx.Exec("INSERT INTO credential_fields (credential_id, name, value) VALUES (?, ?, ?)", key, name, value)
+		if err != nil {
+			return "", common.ChainError("error inserting credential field "+name, err)
+		}
+	}
+
+	return key, nil
+}
+
+func nullableTime(t *time.Time) sql.NullInt64 {
+	if t == nil {
+		return sql.NullInt64{}
+	}
+
+	return sql.NullInt64{Int64: t.UnixNano(), Valid: true}
+}
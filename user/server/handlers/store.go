@@ -0,0 +1,282 @@
+package handlers
+
+import (
+	"errors"
+	"os"
+	"sync"
+	"vcd/common"
+)
+
+var ErrWalletLocked = errors.New("wallet is locked")
+var ErrWrongPassphrase = errors.New("wrong passphrase")
+
+// WalletStore persists the wallet's credentials and private key.
+type WalletStore interface {
+	// Unlock makes the wallet's contents available, returning ErrWrongPassphrase if the passphrase does not open the store.
+	Unlock(passphrase string) error
+	Lock()
+	IsLocked() bool
+
+	// ListCredentials and the other accessors return ErrWalletLocked while the store is locked.
+	ListCredentials(filter CredentialFilter) (CredentialsMap, error)
+	// GetCredential returns ErrCredentialNotFound if there is no credential with the ID.
+	GetCredential(id string) (*common.VerifiableCredential, error)
+	// AddCredential stores the credential, replacing any credential with the same ID, and returns its ID in the wallet.
+	AddCredential(cred *common.VerifiableCredential) (string, error)
+	LoadSigner() (common.Signer, error)
+	// LoadPrivateKey returns the holder's private key as a PKCS #8 PEM block.
+	LoadPrivateKey() ([]byte, error)
+	// LoadDID returns the DID configured for the wallet, or an empty string if it holds credentials under the did:key of its private key.
+	// It is available while the store is locked.
+	LoadDID() (string, error)
+	// Restore replaces the wallet's private key, DID, all of its credentials, its consent log and,
+	// if the store keeps one, its presentation history with the bundle's. Either all of them are replaced or, if it fails, none are.
+	Restore(bundle *WalletBundle) error
+	// SetPrivateKey replaces only the wallet's private key and DID, leaving its other contents as they are.
+	// The consent log is authenticated under the new key if it verifies under the old one.
+	// Either all of them are replaced or, if it fails, none are.
+	SetPrivateKey(privateKey []byte, DID string) error
+	// AppendConsent chains the entry to the end of the consent log under the private key and saves it,
+	// returning ErrConsentLogBroken if the log does not verify.
+	AppendConsent(entry *ConsentEntry) error
+	// ListConsent returns the consent log as it is stored, without verifying it.
+	ListConsent() (*ConsentLog, error)
+}
+
+// WalletPaths locates the plaintext wallet files.
+type WalletPaths struct {
+	Credentials string
+	PrivateKey  string
+	ConsentLog  string
+	DID         string
+}
+
+var DefaultWalletPaths = WalletPaths{
+	Credentials: VC_URI,
+	PrivateKey:  PRIVATE_KEY_URI,
+	ConsentLog:  CONSENT_LOG_URI,
+	DID:         DID_URI,
+}
+
+// Store is the backend the handlers read and write the wallet through, set by the server on startup.
+var Store WalletStore = NewPlainFileStore(DefaultWalletPaths)
+
+// PlainFileStore keeps the credentials and consent log as plaintext JSON and the private key as an unencrypted PEM file.
+// It has no passphrase and is never locked. Updates are serialized and written atomically with a backup.
+type PlainFileStore struct {
+	mu         sync.Mutex
+	credsURI   string
+	privateKey string
+	consentURI string
+	didURI     string
+}
+
+func NewPlainFileStore(paths WalletPaths) *PlainFileStore {
+	return &PlainFileStore{
+		credsURI:   paths.Credentials,
+		privateKey: paths.PrivateKey,
+		consentURI: paths.ConsentLog,
+		didURI:     paths.DID,
+	}
+}
+
+func (s *PlainFileStore) Unlock(_ string) error {
+	return nil
+}
+
+func (s *PlainFileStore) Lock() {}
+
+func (s *PlainFileStore) IsLocked() bool {
+	return false
+}
+
+func (s *PlainFileStore) ListCredentials(filter CredentialFilter) (CredentialsMap, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	creds, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+
+	return creds.filter(filter), nil
+}
+
+func (s *PlainFileStore) GetCredential(id string) (*common.VerifiableCredential, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	creds, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+
+	return creds.get(id)
+}
+
+func (s *PlainFileStore) AddCredential(cred *common.VerifiableCredential) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	creds, err := s.load()
+	if err != nil {
+		return "", err
+	}
+
+	id, err := creds.add(cred)
+	if err != nil {
+		return "", err
+	}
+
+	err = writeWalletFile(s.credsURI, creds)
+	if err != nil {
+		return "", err
+	}
+
+	return id, nil
+}
+
+func (s *PlainFileStore) LoadSigner() (common.Signer, error) {
+	return common.LoadSignerFromFile(s.privateKey, "")
+}
+
+func (s *PlainFileStore) LoadPrivateKey() ([]byte, error) {
+	return common.LoadKeyFromFile(s.privateKey)
+}
+
+func (s *PlainFileStore) LoadDID() (string, error) {
+	return readDIDFile(s.didURI)
+}
+
+func (s *PlainFileStore) Restore(bundle *WalletBundle) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	credsBuffer, err := common.EncodeJSON(bundle.Credentials)
+	if err != nil {
+		return err
+	}
+
+	consentLog := bundle.ConsentLog
+	if consentLog.Entries == nil {
+		consentLog.Entries = []ConsentEntry{}
+	}
+	consentBuffer, err := common.EncodeJSON(&consentLog)
+	if err != nil {
+		return err
+	}
+
+	didUpdate, err := didFileUpdate(s.didURI, bundle.DID, []byte(bundle.PrivateKey))
+	if err != nil {
+		return err
+	}
+
+	return writeWalletFiles([]walletFileUpdate{
+		{uri: s.privateKey, data: []byte(bundle.PrivateKey), backup: true},
+		{uri: s.credsURI, data: credsBuffer.Bytes(), backup: true},
+		{uri: s.consentURI, data: consentBuffer.Bytes(), backup: true},
+		didUpdate,
+	})
+}
+
+func (s *PlainFileStore) SetPrivateKey(privateKey []byte, DID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	didUpdate, err := didFileUpdate(s.didURI, DID, privateKey)
+	if err != nil {
+		return err
+	}
+
+	consentLog, err := s.loadConsent()
+	if err != nil {
+		return err
+	}
+
+	//the old key cannot be read if it was lost, then the log is kept as it is
+	oldPrivateKey, _ := common.LoadKeyFromFile(s.privateKey)
+	err = consentLog.rekey(oldPrivateKey, privateKey)
+	if err != nil {
+		return err
+	}
+
+	consentBuffer, err := common.EncodeJSON(consentLog)
+	if err != nil {
+		return err
+	}
+
+	return writeWalletFiles([]walletFileUpdate{
+		{uri: s.privateKey, data: privateKey, backup: true},
+		{uri: s.consentURI, data: consentBuffer.Bytes(), backup: true},
+		didUpdate,
+	})
+}
+
+func (s *PlainFileStore) AppendConsent(entry *ConsentEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	privateKey, err := common.LoadKeyFromFile(s.privateKey)
+	if err != nil {
+		return err
+	}
+
+	consentLog, err := s.loadConsent()
+	if err != nil {
+		return err
+	}
+
+	err = consentLog.check(privateKey)
+	if err != nil {
+		return err
+	}
+
+	err = consentLog.append(entry, privateKey)
+	if err != nil {
+		return err
+	}
+
+	return writeWalletFile(s.consentURI, consentLog)
+}
+
+func (s *PlainFileStore) ListConsent() (*ConsentLog, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.loadConsent()
+}
+
+// loadConsent reads the consent log file, which is empty until the first presentation. The caller must hold s.mu.
+func (s *PlainFileStore) loadConsent() (*ConsentLog, error) {
+	consentLog := ConsentLog{}
+	err := loadWalletFile(s.consentURI, &consentLog)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	if consentLog.Entries == nil {
+		consentLog.Entries = []ConsentEntry{}
+	}
+	return &consentLog, nil
+}
+
+// load reads the credentials file, which is empty until the first credential is added. The caller must hold s.mu.
+func (s *PlainFileStore) load() (CredentialsMap, error) {
+	creds := CredentialsMap{}
+	err := loadWalletFile(s.credsURI, &creds)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	return creds.rekeyed(), nil
+}
+
+// storeError logs an error from the wallet store and reports whether the wallet needs to be unlocked first.
+func storeError(message string, err error) CustomError {
+	common.LogChainError(message, err)
+
+	if errors.Is(err, ErrWalletLocked) {
+		return LockedError()
+	}
+	return InternalError()
+}
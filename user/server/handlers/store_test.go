@@ -0,0 +1,465 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+	"vcd/common"
+)
+
+const (
+	CONCURRENT_CREDENTIALS = 100
+	CONCURRENT_WORKERS     = 10
+)
+
+func newTestStores(t *testing.T) map[string]WalletStore {
+	dir := t.TempDir()
+	paths := func(name string) WalletPaths {
+		return WalletPaths{
+			Credentials: filepath.Join(dir, name+"-credentials.json"),
+			PrivateKey:  filepath.Join(dir, name+"-private.key"),
+			ConsentLog:  filepath.Join(dir, name+"-consent-log.json"),
+			DID:         filepath.Join(dir, name+"-DID.txt"),
+		}
+	}
+
+	encrypted := NewEncryptedFileStore(filepath.Join(dir, "wallet.enc.json"), paths("encrypted"))
+	err := encrypted.Unlock("passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sqlite, err := NewSQLiteStore(filepath.Join(dir, "wallet.db"), paths("sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		sqlite.db.Close()
+	})
+
+	return map[string]WalletStore{
+		"plain":     NewPlainFileStore(paths("plain")),
+		"encrypted": encrypted,
+		"sqlite":    sqlite,
+	}
+}
+
+// TestConcurrentAddCredential adds credentials from several goroutines at once and checks none of them are lost.
+func TestConcurrentAddCredential(t *testing.T) {
+	for name, store := range newTestStores(t) {
+		store := store
+		t.Run(name, func(t *testing.T) {
+			ids := make(chan string, CONCURRENT_CREDENTIALS)
+			errs := make(chan error, CONCURRENT_CREDENTIALS)
+
+			jobs := make(chan int)
+			wg := sync.WaitGroup{}
+			for w := 0; w < CONCURRENT_WORKERS; w++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for i := range jobs {
+						id, err := store.AddCredential(&common.VerifiableCredential{
+							ID:          fmt.Sprintf("credential-%d", i),
+							CredType:    "Test Credential",
+							Credentials: map[string]string{"Number": fmt.Sprint(i)},
+						})
+						if err != nil {
+							errs <- err
+							continue
+						}
+						ids <- id
+					}
+				}()
+			}
+
+			for i := 0; i < CONCURRENT_CREDENTIALS; i++ {
+				jobs <- i
+			}
+			close(jobs)
+			wg.Wait()
+			close(ids)
+			close(errs)
+
+			for err := range errs {
+				t.Error(err)
+			}
+
+			creds, err := store.ListCredentials(CredentialFilter{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(creds) != CONCURRENT_CREDENTIALS {
+				t.Fatalf("expected %d credentials, found %d", CONCURRENT_CREDENTIALS, len(creds))
+			}
+
+			for id := range ids {
+				cred, err := store.GetCredential(id)
+				if err != nil {
+					t.Fatalf("credential %s was not saved: %v", id, err)
+				}
+				if cred.Credentials["Number"] != id[len("credential-"):] {
+					t.Errorf("credential %s has the wrong contents", id)
+				}
+			}
+		})
+	}
+}
+
+func newTestBundle(t *testing.T, DID string) *WalletBundle {
+	t.Helper()
+
+	key, err := common.GenerateKey("ed25519")
+	if err != nil {
+		t.Fatal(err)
+	}
+	privateKey, err := common.EncodePrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	consentLog := ConsentLog{Entries: []ConsentEntry{}}
+	err = consentLog.append(&ConsentEntry{VerifierDID: "did:example:verifier", Outcome: OUTCOME_ACCEPTED}, privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &WalletBundle{
+		DID:         DID,
+		PrivateKey:  string(privateKey),
+		Credentials: CredentialsMap{DID: {ID: DID, CredType: "Test Credential"}},
+		ConsentLog:  consentLog,
+		Presentations: []PresentationRecord{{
+			ServiceURL:  "http://localhost/" + DID,
+			PresentedAt: time.Now().UTC(),
+			Accepted:    true,
+			Credentials: []PresentedCredential{{ID: DID, CredType: "Test Credential", Fields: []string{}}},
+		}},
+	}
+}
+
+// TestRestore replaces each store's contents, and checks a restore that fails part way leaves the wallet as it was.
+func TestRestore(t *testing.T) {
+	for name, store := range newTestStores(t) {
+		store := store
+		t.Run(name, func(t *testing.T) {
+			bundle := newTestBundle(t, "did:example:restored")
+			err := store.Restore(bundle)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			checkRestored := func() {
+				t.Helper()
+
+				privateKey, err := store.LoadPrivateKey()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(privateKey) != bundle.PrivateKey {
+					t.Error("private key was not restored")
+				}
+
+				DID, err := store.LoadDID()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if DID != bundle.DID {
+					t.Errorf("expected DID %s, found %s", bundle.DID, DID)
+				}
+
+				creds, err := store.ListCredentials(CredentialFilter{})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(creds) != 1 || creds[bundle.DID].ID != bundle.DID {
+					t.Errorf("credentials were not restored, found %d", len(creds))
+				}
+
+				consentLog, err := store.ListConsent()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(consentLog.Entries) != 1 || consentLog.HeadMAC != bundle.ConsentLog.HeadMAC {
+					t.Errorf("consent log was not restored, found %d entries", len(consentLog.Entries))
+				}
+
+				if history, ok := store.(PresentationHistory); ok {
+					records, err := history.ListPresentations("")
+					if err != nil {
+						t.Fatal(err)
+					}
+					if len(records) != 1 || records[0].ServiceURL != bundle.Presentations[0].ServiceURL {
+						t.Errorf("presentation history was not restored, found %d presentations", len(records))
+					}
+				}
+			}
+			checkRestored()
+
+			//the DID file is committed last, so failing to rename it must put back every file renamed before it
+			var didURI string
+			switch s := store.(type) {
+			case *PlainFileStore:
+				didURI = s.didURI
+			case *EncryptedFileStore:
+				didURI = s.didURI
+			case *SQLiteStore:
+				didURI = s.didURI
+			}
+
+			commitTempFile = func(tmpName string, filename string) error {
+				if filename == didURI {
+					os.Remove(tmpName)
+					return errors.New("injected rename failure")
+				}
+				return common.CommitTempFile(tmpName, filename)
+			}
+			defer func() {
+				commitTempFile = common.CommitTempFile
+			}()
+
+			err = store.Restore(newTestBundle(t, "did:example:failed"))
+			if err == nil {
+				t.Fatal("expected the restore to fail")
+			}
+			checkRestored()
+		})
+	}
+}
+
+// TestSetPrivateKey replaces each store's key and checks the rest of the wallet is kept.
+func TestSetPrivateKey(t *testing.T) {
+	for name, store := range newTestStores(t) {
+		store := store
+		t.Run(name, func(t *testing.T) {
+			bundle := newTestBundle(t, "did:example:restored")
+			err := store.Restore(bundle)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			//the new key holds credentials under its did:key, so the DID file is removed
+			key, err := common.GenerateKey("ed25519")
+			if err != nil {
+				t.Fatal(err)
+			}
+			privateKey, err := common.EncodePrivateKey(key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			keyDID, err := common.EncodeDIDKey(key.Public())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = store.SetPrivateKey(privateKey, keyDID)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			loadedKey, err := store.LoadPrivateKey()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(loadedKey) != string(privateKey) {
+				t.Error("private key was not replaced")
+			}
+
+			DID, err := store.LoadDID()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if DID != "" {
+				t.Errorf("expected no DID file, found %s", DID)
+			}
+
+			creds, err := store.ListCredentials(CredentialFilter{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(creds) != 1 {
+				t.Errorf("expected the credentials to be kept, found %d", len(creds))
+			}
+
+			consentLog, err := store.ListConsent()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(consentLog.Entries) != 1 {
+				t.Errorf("expected the consent log to be kept, found %d entries", len(consentLog.Entries))
+			}
+
+			//the log is authenticated under the new key instead of the old one
+			err = consentLog.check(privateKey)
+			if err != nil {
+				t.Error(err)
+			}
+			if consentLog.check([]byte(bundle.PrivateKey)) == nil {
+				t.Error("consent log still verifies under the old key")
+			}
+		})
+	}
+}
+
+// TestConsentLog appends to each store's consent log, and checks it is not extended once it has been changed.
+func TestConsentLog(t *testing.T) {
+	for name, store := range newTestStores(t) {
+		store := store
+		t.Run(name, func(t *testing.T) {
+			bundle := newTestBundle(t, "did:example:restored")
+			err := store.Restore(bundle)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for i := 0; i < 2; i++ {
+				err = store.AppendConsent(&ConsentEntry{VerifierDID: "did:example:verifier", Outcome: OUTCOME_REJECTED})
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			consentLog, err := store.ListConsent()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(consentLog.Entries) != 3 {
+				t.Fatalf("expected 3 entries, found %d", len(consentLog.Entries))
+			}
+			err = consentLog.check([]byte(bundle.PrivateKey))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			//a log with an entry removed from its end is restored as it is, and is not extended
+			truncated := *bundle
+			truncated.ConsentLog = ConsentLog{Entries: consentLog.Entries[:2], HeadMAC: consentLog.HeadMAC}
+			err = store.Restore(&truncated)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = store.AppendConsent(&ConsentEntry{VerifierDID: "did:example:verifier", Outcome: OUTCOME_ACCEPTED})
+			if !errors.Is(err, ErrConsentLogBroken) {
+				t.Errorf("expected ErrConsentLogBroken, got %v", err)
+			}
+		})
+	}
+}
+
+func TestConsentLogVerify(t *testing.T) {
+	privateKey := []byte(newTestBundle(t, "did:example:holder").PrivateKey)
+	otherKey := []byte(newTestBundle(t, "did:example:other").PrivateKey)
+
+	newLog := func() *ConsentLog {
+		consentLog := ConsentLog{Entries: []ConsentEntry{}}
+		for _, outcome := range []string{OUTCOME_ACCEPTED, OUTCOME_REJECTED, OUTCOME_FAILED} {
+			err := consentLog.append(&ConsentEntry{VerifierDID: "did:example:verifier", Outcome: outcome}, privateKey)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		return &consentLog
+	}
+
+	for _, tc := range []struct {
+		name     string
+		change   func(l *ConsentLog)
+		key      []byte
+		brokenAt int64
+	}{
+		{"intact", func(l *ConsentLog) {}, privateKey, -1},
+		{"empty", func(l *ConsentLog) { *l = ConsentLog{} }, privateKey, -1},
+		{"wrong key", func(l *ConsentLog) {}, otherKey, 0},
+		{"changed entry", func(l *ConsentLog) { l.Entries[1].Outcome = OUTCOME_ACCEPTED }, privateKey, 1},
+		{"removed entry", func(l *ConsentLog) { l.Entries = append(l.Entries[:1], l.Entries[2:]...) }, privateKey, 1},
+		{"removed last entry", func(l *ConsentLog) { l.Entries = l.Entries[:2] }, privateKey, 2},
+		{"removed every entry", func(l *ConsentLog) { l.Entries = []ConsentEntry{} }, privateKey, 0},
+		{"removed head", func(l *ConsentLog) { l.HeadMAC = "" }, privateKey, 3},
+		{"rehashed without the key", func(l *ConsentLog) {
+			l.Entries[2].Outcome = OUTCOME_ACCEPTED
+			sum := sha256.Sum256([]byte(l.Entries[2].PrevHash + l.Entries[2].Outcome))
+			l.Entries[2].Hash = hex.EncodeToString(sum[:])
+		}, privateKey, 2},
+	} {
+		consentLog := newLog()
+		tc.change(consentLog)
+
+		brokenAt, err := consentLog.verify(tc.key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if brokenAt != tc.brokenAt {
+			t.Errorf("%s: expected the log to break at %d, got %d", tc.name, tc.brokenAt, brokenAt)
+		}
+	}
+
+	//rekeying a log that verifies authenticates it under the new key, and leaves a broken log as it is
+	consentLog := newLog()
+	err := consentLog.rekey(privateKey, otherKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if consentLog.check(otherKey) != nil || consentLog.check(privateKey) == nil {
+		t.Error("log was not rekeyed")
+	}
+
+	consentLog.Entries[0].Outcome = OUTCOME_FAILED
+	err = consentLog.rekey(otherKey, privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if consentLog.check(privateKey) == nil {
+		t.Error("broken log was rekeyed")
+	}
+}
+
+// TestEncryptedStoreMigratesCorruptFile checks the credentials are moved into a new encrypted wallet from the backup
+// of a plaintext credentials file that is corrupt.
+func TestEncryptedStoreMigratesCorruptFile(t *testing.T) {
+	dir := t.TempDir()
+	paths := WalletPaths{
+		Credentials: filepath.Join(dir, "credentials.json"),
+		PrivateKey:  filepath.Join(dir, "private.key"),
+		ConsentLog:  filepath.Join(dir, "consent-log.json"),
+		DID:         filepath.Join(dir, "DID.txt"),
+	}
+
+	err := common.WriteJSONToFile(paths.Credentials+BACKUP_EXT, CredentialsMap{
+		"credential-1": {ID: "credential-1", CredType: "Test Credential"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(paths.Credentials, []byte(`{"credential-1": {"id": "cred`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := NewEncryptedFileStore(filepath.Join(dir, "wallet.enc.json"), paths)
+	err = store.Unlock("passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	creds, err := store.ListCredentials(CredentialFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := creds["credential-1"]; !ok || len(creds) != 1 {
+		t.Errorf("expected the backed up credential to be migrated, found %d credentials", len(creds))
+	}
+
+	for _, uri := range []string{paths.Credentials, paths.Credentials + BACKUP_EXT} {
+		_, err = os.Stat(uri)
+		if !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("%s was not removed", uri)
+		}
+	}
+}
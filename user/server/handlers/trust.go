@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+	"vcd/common"
+)
+
+const TRUST_URI = "wallet/trust.json"
+
+// how many endorsements may separate an entity from a trust anchor when not configured
+const DEFAULT_MAX_TRUST_DEPTH = 3
+
+// how long a fetched trust registry is reused, how long fetching it may take, and the largest registry read
+const (
+	TRUST_REGISTRY_TTL      = 5 * time.Minute
+	TRUST_REGISTRY_TIMEOUT  = 10 * time.Second
+	TRUST_REGISTRY_MAX_SIZE = 1 << 20
+)
+
+var registryClient = &http.Client{Timeout: TRUST_REGISTRY_TIMEOUT}
+
+type cachedRegistry struct {
+	registry  *common.TrustRegistry
+	url       string
+	DID       string
+	expiresAt time.Time
+}
+
+// the last registry fetched, with the config it was fetched for
+var registryCache = struct {
+	sync.Mutex
+	cachedRegistry
+}{}
+
+type TrustConfig struct {
+	Anchors  []string `json:"anchors"`
+	MaxDepth int      `json:"max_depth,omitempty"`
+
+	//trust registry consulted for the issuers accredited for each credential type
+	RegistryURL string `json:"registry_url,omitempty"`
+	RegistryDID string `json:"registry_did,omitempty"`
+}
+
+// loadTrustConfig loads the wallet's trust anchors, a wallet without a config trusts no one.
+func loadTrustConfig() (*TrustConfig, error) {
+	config := TrustConfig{}
+
+	err := common.LoadJSONFromFile(TRUST_URI, &config)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, common.ChainError("error loading trust config", err)
+	}
+
+	if config.MaxDepth <= 0 {
+		config.MaxDepth = DEFAULT_MAX_TRUST_DEPTH
+	}
+
+	return &config, nil
+}
+
+// getTrustRegistry returns the configured registry, reusing one fetched within TRUST_REGISTRY_TTL unless refresh is set.
+func getTrustRegistry(config *TrustConfig, refresh bool) (*common.TrustRegistry, error) {
+	now := time.Now()
+
+	registryCache.Lock()
+	cached := registryCache.cachedRegistry
+	registryCache.Unlock()
+
+	if !refresh && cached.registry != nil && cached.url == config.RegistryURL && cached.DID == config.RegistryDID && now.Before(cached.expiresAt) {
+		return cached.registry, nil
+	}
+
+	registry, err := fetchTrustRegistry(config)
+	if err != nil {
+		return nil, err
+	}
+
+	registryCache.Lock()
+	registryCache.cachedRegistry = cachedRegistry{
+		registry:  registry,
+		url:       config.RegistryURL,
+		DID:       config.RegistryDID,
+		expiresAt: now.Add(TRUST_REGISTRY_TTL),
+	}
+	registryCache.Unlock()
+
+	return registry, nil
+}
+
+// fetchTrustRegistry gets the signed registry from the configured registry service and verifies it was signed by the operator.
+func fetchTrustRegistry(config *TrustConfig) (*common.TrustRegistry, error) {
+	res, err := registryClient.Get(config.RegistryURL)
+	if err != nil {
+		return nil, common.ChainError("error getting trust registry", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, errors.New("error getting trust registry: " + res.Status)
+	}
+
+	registry := common.TrustRegistry{}
+	err = common.DecodeJSON(io.LimitReader(res.Body, TRUST_REGISTRY_MAX_SIZE), &registry)
+	if err != nil {
+		return nil, common.ChainError("error decoding trust registry", err)
+	}
+
+	err = common.VerifyTrustRegistry(&registry, config.RegistryDID)
+	if err != nil {
+		return nil, common.ChainError("error verifying trust registry", err)
+	}
+
+	return &registry, nil
+}
@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"vcd/common"
+)
+
+// the previous version of a wallet file is kept alongside it with this extension
+const BACKUP_EXT = ".bak"
+
+// walletFileUpdate is the new contents of a wallet file written by writeWalletFiles.
+type walletFileUpdate struct {
+	uri string
+	//nil removes the file
+	data []byte
+
+	//keep the current contents as a backup
+	backup bool
+}
+
+// commitTempFile renames a temporary file into place, replaced in tests to fail part way through a commit.
+var commitTempFile = common.CommitTempFile
+
+// writeWalletFile keeps the current contents of the file as a backup, then atomically replaces the file.
+// Callers serialize writes to the same file, which the wallet stores do by holding their mutex.
+func writeWalletFile(uri string, v interface{}) error {
+	buffer, err := common.EncodeJSON(v)
+	if err != nil {
+		return err
+	}
+
+	return writeWalletFiles([]walletFileUpdate{{uri: uri, data: buffer.Bytes(), backup: true}})
+}
+
+// writeWalletFiles replaces the files together, so that either all of them are replaced or none are.
+func writeWalletFiles(updates []walletFileUpdate) error {
+	_, err := commitWalletFiles(updates)
+	return err
+}
+
+// commitWalletFiles writes every file to a temporary file first, and none are renamed into place until all of them
+// have been written. If renaming one fails, the files already renamed are put back as they were.
+// The returned function does the same after a successful commit, for callers with other changes that then fail.
+func commitWalletFiles(updates []walletFileUpdate) (func(), error) {
+	tmpNames := make([]string, len(updates))
+	defer func() {
+		for _, tmpName := range tmpNames {
+			if tmpName != "" {
+				os.Remove(tmpName)
+			}
+		}
+	}()
+
+	for i, update := range updates {
+		if update.data == nil {
+			continue
+		}
+
+		tmpName, err := common.WriteTempFile(update.uri, update.data, 0600)
+		if err != nil {
+			return nil, common.ChainError("error writing wallet file", err)
+		}
+		tmpNames[i] = tmpName
+	}
+
+	//the current contents are kept in memory to roll back to, nil if the file does not exist
+	previous := make([][]byte, len(updates))
+	for i, update := range updates {
+		current, err := os.ReadFile(update.uri)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, common.ChainError("error reading wallet file", err)
+		}
+		previous[i] = current
+
+		if update.backup {
+			err = common.WriteFileAtomic(update.uri+BACKUP_EXT, current, 0600)
+			if err != nil {
+				return nil, common.ChainError("error writing wallet backup", err)
+			}
+		}
+	}
+
+	rollback := func(committed int) {
+		for i := committed - 1; i >= 0; i-- {
+			uri := updates[i].uri
+
+			var err error
+			if previous[i] == nil {
+				err = os.Remove(uri)
+				if errors.Is(err, os.ErrNotExist) {
+					err = nil
+				}
+			} else {
+				err = common.WriteFileAtomic(uri, previous[i], 0600)
+			}
+			if err != nil {
+				common.LogChainError("error rolling back wallet file "+uri, err)
+			}
+		}
+	}
+
+	for i, update := range updates {
+		var err error
+		if update.data == nil {
+			err = os.Remove(update.uri)
+			if errors.Is(err, os.ErrNotExist) {
+				err = nil
+			}
+		} else {
+			err = commitTempFile(tmpNames[i], update.uri)
+			tmpNames[i] = ""
+		}
+
+		if err != nil {
+			rollback(i)
+			return nil, common.ChainError("error replacing wallet file", err)
+		}
+	}
+
+	return func() { rollback(len(updates)) }, nil
+}
+
+// loadWalletFile decodes the file, falling back to its backup if the file is unreadable or corrupt.
+func loadWalletFile(uri string, v interface{}) error {
+	err := decodeWalletFile(uri, v)
+	if err == nil || errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	backupErr := decodeWalletFile(uri+BACKUP_EXT, v)
+	if backupErr != nil {
+		return err
+	}
+
+	log.Println(common.ChainError("wallet file "+uri+" is corrupt, loaded backup instead", err))
+	return nil
+}
+
+func decodeWalletFile(uri string, v interface{}) error {
+	bytes, err := os.ReadFile(uri)
+	if err != nil {
+		return common.ChainError("error reading wallet file", err)
+	}
+
+	//unmarshal checks the whole file is valid JSON before decoding into v, so a truncated file leaves v untouched
+	err = json.Unmarshal(bytes, v)
+	if err != nil {
+		return common.ChainError("error decoding wallet file", err)
+	}
+
+	return nil
+}
@@ -3,6 +3,8 @@ package main
 import (
 	"flag"
 	"fmt"
+	"log"
+	"mime"
 	"net/http"
 	"vcd/common"
 	"vcd/user/server/handlers"
@@ -18,6 +20,11 @@ func createHandler(method string, handler http.HandlerFunc) http.HandlerFunc {
 		case http.MethodOptions:
 			return
 		case method:
+			//a cross-site form cannot send JSON or a JWT without a preflight, which only the wallet client is allowed through
+			if method == http.MethodPost && !hasPreflightedContentType(req) {
+				common.SendErrorResponse(w, http.StatusUnsupportedMediaType, "Content-Type must be application/json or application/jwt")
+				return
+			}
 			handler(w, req)
 		default:
 			common.SendErrorResponse(w, http.StatusBadRequest, "invalid request method")
@@ -25,17 +32,56 @@ func createHandler(method string, handler http.HandlerFunc) http.HandlerFunc {
 	}
 }
 
+func hasPreflightedContentType(req *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	return err == nil && (mediaType == "application/json" || mediaType == common.JWT_MEDIA_TYPE)
+}
+
 func main() {
 	//parse flags
 	port := flag.Int("port", 8082, "port to run the server on")
+	blockchain := flag.String("blockchain", common.BlockchainDir, "directory of the local did:example registry")
+	store := flag.String("store", "encrypted", "wallet storage backend, 'encrypted', 'plain' or 'sqlite'")
 	flag.Parse()
 
+	common.BlockchainDir = *blockchain
+
+	switch *store {
+	case "encrypted":
+		handlers.Store = handlers.NewEncryptedFileStore(handlers.ENCRYPTED_WALLET_URI, handlers.DefaultWalletPaths)
+	case "plain":
+		handlers.Store = handlers.NewPlainFileStore(handlers.DefaultWalletPaths)
+	case "sqlite":
+		sqliteStore, err := handlers.NewSQLiteStore(handlers.SQLITE_WALLET_URI, handlers.DefaultWalletPaths)
+		if err != nil {
+			log.Fatal(err)
+		}
+		handlers.Store = sqliteStore
+	default:
+		log.Fatalf("unknown wallet store '%s'", *store)
+	}
+
 	//setup routes
 	http.HandleFunc("/creds", createHandler(http.MethodGet, handlers.GetCredsHandler))
 	http.HandleFunc("/cred", createHandler(http.MethodGet, handlers.GetCredHandler))
 	http.HandleFunc("/query", createHandler(http.MethodGet, handlers.GetQueryHandler))
 	http.HandleFunc("/verify", createHandler(http.MethodPost, handlers.PostVerifyHandler))
 	http.HandleFunc("/issue", createHandler(http.MethodPost, handlers.PostIssueHandler))
+	http.HandleFunc("/export", createHandler(http.MethodGet, handlers.GetExportHandler))
+	http.HandleFunc("/import", createHandler(http.MethodPost, handlers.PostImportHandler))
+	http.HandleFunc("/status", createHandler(http.MethodGet, handlers.GetStatusHandler))
+	http.HandleFunc("/unlock", createHandler(http.MethodPost, handlers.PostUnlockHandler))
+	http.HandleFunc("/lock", createHandler(http.MethodPost, handlers.PostLockHandler))
+	http.HandleFunc("/presentations", createHandler(http.MethodGet, handlers.GetPresentationsHandler))
+	http.HandleFunc("/history", createHandler(http.MethodGet, handlers.GetHistoryHandler))
+	http.HandleFunc("/backup", createHandler(http.MethodPost, handlers.PostBackupHandler))
+	http.HandleFunc("/restore", createHandler(http.MethodPost, handlers.PostRestoreHandler))
+	http.HandleFunc("/shares", createHandler(http.MethodPost, handlers.PostSharesHandler))
+	http.HandleFunc("/recover", createHandler(http.MethodPost, handlers.PostRecoverHandler))
+
+	if handlers.Store.IsLocked() {
+		fmt.Println("wallet is locked, unlock it with POST /unlock")
+	}
 
 	//run the server
 	fmt.Printf("listening on port %d...\n", *port)
@@ -0,0 +1,78 @@
+package verifier
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"sync"
+	"time"
+	"vcd/common"
+)
+
+// how many unexpired nonces a store holds before it refuses to create more, as anyone can request one
+const MaxOutstandingNonces = 10000
+
+var ErrTooManyNonces = errors.New("too many outstanding nonces")
+var ErrNoNonceStore = errors.New("verifier service has no nonce store")
+
+type NonceStore struct {
+	mutex  sync.Mutex
+	ttl    time.Duration
+	max    int
+	nonces map[string]time.Time
+}
+
+func NewNonceStore(ttl time.Duration) *NonceStore {
+	return &NonceStore{
+		ttl:    ttl,
+		max:    MaxOutstandingNonces,
+		nonces: map[string]time.Time{},
+	}
+}
+
+// Create generates a new nonce that is valid for the store's ttl,
+// returning ErrTooManyNonces if the store already holds the maximum number of unexpired nonces.
+func (s *NonceStore) Create() (string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := time.Now()
+	s.removeExpired(now)
+	if len(s.nonces) >= s.max {
+		return "", ErrTooManyNonces
+	}
+
+	bytes := make([]byte, 32)
+
+	_, err := rand.Read(bytes)
+	if err != nil {
+		return "", common.ChainError("error generating random bytes", err)
+	}
+	nonce := base64.RawURLEncoding.EncodeToString(bytes)
+
+	s.nonces[nonce] = now.Add(s.ttl)
+
+	return nonce, nil
+}
+
+// Consume returns true if the nonce was issued by the store and has not expired or already been used.
+func (s *NonceStore) Consume(nonce string) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	expiresAt, ok := s.nonces[nonce]
+	if !ok {
+		return false
+	}
+	delete(s.nonces, nonce)
+
+	return time.Now().Before(expiresAt)
+}
+
+func (s *NonceStore) removeExpired(now time.Time) {
+	for nonce, expiresAt := range s.nonces {
+		if !now.Before(expiresAt) {
+			delete(s.nonces, nonce)
+		}
+	}
+}
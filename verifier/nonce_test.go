@@ -0,0 +1,47 @@
+package verifier
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestNonceStoreLimit(t *testing.T) {
+	store := NewNonceStore(time.Minute)
+	store.max = 3
+
+	nonces := []string{}
+	for i := 0; i < store.max; i++ {
+		nonce, err := store.Create()
+		if err != nil {
+			t.Fatal(err)
+		}
+		nonces = append(nonces, nonce)
+	}
+
+	_, err := store.Create()
+	if !errors.Is(err, ErrTooManyNonces) {
+		t.Fatalf("expected ErrTooManyNonces, got %v", err)
+	}
+
+	//consuming a nonce frees its place
+	if !store.Consume(nonces[0]) {
+		t.Fatal("nonce was not accepted")
+	}
+	_, err = store.Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//expired nonces are pruned when a nonce is created
+	for nonce := range store.nonces {
+		store.nonces[nonce] = time.Now().Add(-time.Second)
+	}
+	_, err = store.Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(store.nonces) != 1 {
+		t.Fatalf("expected expired nonces to be removed, %d remain", len(store.nonces))
+	}
+}
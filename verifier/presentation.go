@@ -0,0 +1,185 @@
+package verifier
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+	"vcd/common"
+)
+
+// how far a presentation timestamp may drift from the verifier's clock
+const MaxPresentationSkew = 5 * time.Minute
+
+var ErrHolderSignature = errors.New("error verifying holder signature")
+var ErrIssuerSignature = errors.New("error verifying issuer signature")
+var ErrSubjectMismatch = errors.New("credential subject does not match presentation holder")
+var ErrAudienceMismatch = errors.New("presentation was not made to this verifier")
+var ErrInvalidNonce = errors.New("invalid, expired, or already used nonce")
+var ErrStalePresentation = errors.New("presentation timestamp is outside the allowed window")
+var ErrNoCredentials = errors.New("presentation contains no credentials")
+var ErrRequirementsNotMet = errors.New("presented credentials do not satisfy the presentation request")
+
+// errors that are the fault of the presented credentials rather than the verifier
+var verificationErrors = []error{
+	ErrHolderSignature,
+	ErrIssuerSignature,
+	ErrSubjectMismatch,
+	ErrAudienceMismatch,
+	ErrInvalidNonce,
+	ErrStalePresentation,
+	ErrNoCredentials,
+	ErrRequirementsNotMet,
+	common.ErrInvalidDisclosure,
+	common.ErrCredentialExpired,
+	common.ErrCredentialNotYetValid,
+	common.ErrCredentialRevoked,
+	common.ErrInvalidDID,
+	common.ErrMethodNotSupported,
+	common.ErrDIDNotFound,
+	common.ErrIssuerNotAccredited,
+}
+
+// VerifyIssuerSignature verifies the issuer signature and disclosed fields of the credential,
+// without checking whether it is currently valid. The credential is not modified.
+func VerifyIssuerSignature(cred *common.VerifiableCredential) error {
+	doc, err := common.LoadDIDDocumentFromURI(cred.Issuer.DID)
+	if err != nil {
+		return common.ChainError("error loading issuer DID document", err)
+	}
+
+	if cred.JWT != "" {
+		err = common.VerifyJWTCredential(cred, doc)
+	} else {
+		//verify with the key that was valid when the credential was issued
+		issuedAt := time.Time{}
+		if cred.IssuedAt != nil {
+			issuedAt = *cred.IssuedAt
+		}
+
+		signed := cred.Redacted()
+		signed.Subject = common.Signature{DID: signed.Subject.DID}
+		err = common.VerifyDocumentSignature(doc, common.ASSERTION_METHOD, issuedAt, &signed.Issuer, &signed)
+	}
+	if err != nil {
+		common.LogChainError("error verifying issuer signature", err)
+		return ErrIssuerSignature
+	}
+
+	return cred.VerifyDisclosures()
+}
+
+// VerifyCredential verifies the issuer signature, disclosed fields, validity period, and revocation status of the credential.
+// The credential is not modified.
+func VerifyCredential(cred *common.VerifiableCredential) error {
+	err := VerifyIssuerSignature(cred)
+	if err != nil {
+		return err
+	}
+
+	err = cred.CheckValidityPeriod(time.Now())
+	if err != nil {
+		return err
+	}
+
+	return common.CheckRevocationStatus(cred)
+}
+
+// VerifyPresentation verifies the holder proof of the presentation and returns the credentials satisfying each requirement
+// after verifying they are valid and bound to the holder. Its nonce is consumed once all of these checks pass.
+// The credentials are matched before any issuer DID is resolved, so issuers that are not accepted are never contacted.
+func VerifyPresentation(vp *common.VerifiablePresentation, audience string, reqs []common.CredentialRequirement, nonces *NonceStore) ([]common.VerifiableCredential, error) {
+	if nonces == nil {
+		return nil, ErrNoNonceStore
+	}
+
+	if len(vp.Credentials) == 0 {
+		return nil, ErrNoCredentials
+	}
+
+	holderDoc, err := common.LoadDIDDocumentFromURI(vp.Holder.DID)
+	if err != nil {
+		common.LogChainError("error resolving holder DID", err)
+		return nil, ErrHolderSignature
+	}
+
+	signed := *vp
+	err = common.VerifyDocumentSignature(holderDoc, common.AUTHENTICATION, vp.Timestamp, &signed.Holder, &signed)
+	if err != nil {
+		common.LogChainError("error verifying holder signature", err)
+		return nil, ErrHolderSignature
+	}
+
+	if vp.Audience != audience {
+		return nil, ErrAudienceMismatch
+	}
+
+	skew := time.Since(vp.Timestamp)
+	if skew > MaxPresentationSkew || skew < -MaxPresentationSkew {
+		return nil, ErrStalePresentation
+	}
+
+	for i := range vp.Credentials {
+		if vp.Credentials[i].Subject.DID != vp.Holder.DID {
+			return nil, ErrSubjectMismatch
+		}
+	}
+
+	matched, err := MatchRequirements(reqs, vp.Credentials)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range matched {
+		err = VerifyCredential(&matched[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	//the nonce is only used up by a presentation that passes every other check, so a rejected one can be corrected and resent
+	if !nonces.Consume(vp.Nonce) {
+		return nil, ErrInvalidNonce
+	}
+
+	return matched, nil
+}
+
+// MatchRequirements returns the credentials satisfying each requirement, in requirement order.
+func MatchRequirements(reqs []common.CredentialRequirement, creds []common.VerifiableCredential) ([]common.VerifiableCredential, error) {
+	matched, err := common.MatchRequirements(reqs, creds)
+	if err != nil {
+		common.LogChainError("error matching credentials to requirements", err)
+		return nil, ErrRequirementsNotMet
+	}
+
+	return matched, nil
+}
+
+func findVerificationError(err error) error {
+	for _, verr := range verificationErrors {
+		if errors.Is(err, verr) {
+			return verr
+		}
+	}
+
+	return nil
+}
+
+// IsVerificationError returns true if the error was caused by the presented credentials rather than the verifier.
+func IsVerificationError(err error) bool {
+	return findVerificationError(err) != nil
+}
+
+// SendVerificationError sends an unauthorized response if the error was caused by the presented credentials,
+// otherwise it logs the error and sends an internal error response.
+func SendVerificationError(w http.ResponseWriter, err error) {
+	verr := findVerificationError(err)
+	if verr != nil {
+		common.SendErrorResponse(w, http.StatusUnauthorized, verr.Error())
+		return
+	}
+
+	log.Println(err)
+	common.SendInternalErrorResponse(w)
+}
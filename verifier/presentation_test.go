@@ -0,0 +1,207 @@
+package verifier
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+	"vcd/common"
+)
+
+// newTestPresentation signs a presentation of a credential from an issuer that is not accepted, with a did:key holder.
+func newTestPresentation(t *testing.T, nonce string) *common.VerifiablePresentation {
+	t.Helper()
+
+	key, err := common.GenerateKey("ed25519")
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := common.NewSigner(key, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	holder, err := common.EncodeDIDKey(key.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vp := common.VerifiablePresentation{
+		Credentials: []common.VerifiableCredential{{
+			CredType: "Test Credential",
+			Issuer:   common.Signature{DID: "did:example:unaccepted"},
+			Subject:  common.Signature{DID: holder},
+		}},
+		Nonce:     nonce,
+		Audience:  "did:example:verifier",
+		Timestamp: time.Now().UTC(),
+		Holder:    common.Signature{DID: holder},
+	}
+
+	err = common.SignStructWithSigner(signer, &vp.Holder, &vp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &vp
+}
+
+func TestRejectedPresentationKeepsNonce(t *testing.T) {
+	nonces := NewNonceStore(time.Minute)
+	nonce, err := nonces.Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reqs := []common.CredentialRequirement{{
+		CredType: "Test Credential",
+		Issuers:  []string{"did:example:accepted"},
+	}}
+
+	_, err = VerifyPresentation(newTestPresentation(t, nonce), "did:example:verifier", reqs, nonces)
+	if !errors.Is(err, ErrRequirementsNotMet) {
+		t.Fatalf("expected ErrRequirementsNotMet, got %v", err)
+	}
+
+	if !nonces.Consume(nonce) {
+		t.Fatal("nonce was consumed by a rejected presentation")
+	}
+}
+
+func TestVerifierServiceWithoutNonceStore(t *testing.T) {
+	s := VerifierService{DID: "did:example:verifier"}
+
+	w := httptest.NewRecorder()
+	s.GetVerifyHandler(w, httptest.NewRequest(http.MethodGet, "/verify", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("GET: expected status 500, got %d", w.Code)
+	}
+
+	_, err := VerifyPresentation(newTestPresentation(t, "nonce"), s.DID, nil, s.Nonces)
+	if !errors.Is(err, ErrNoNonceStore) {
+		t.Errorf("expected ErrNoNonceStore, got %v", err)
+	}
+}
+
+// TestPresentationWithEncodedJWTCredential presents a JWT-VC as its JWT followed by a disclosure,
+// with the holder signature over the presentation as it is sent.
+func TestPresentationWithEncodedJWTCredential(t *testing.T) {
+	common.BlockchainDir = t.TempDir()
+
+	issuerKey, err := common.GenerateKey("ed25519")
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuerKeyURI := filepath.Join(t.TempDir(), "issuer.key")
+	err = common.SavePrivateKeyToFile(issuerKeyURI, issuerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuer, err := common.EncodeDIDKey(issuerKey.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	holderKey, err := common.GenerateKey("ed25519")
+	if err != nil {
+		t.Fatal(err)
+	}
+	holderSigner, err := common.NewSigner(holderKey, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	holder, err := common.EncodeDIDKey(holderKey.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cred := common.VerifiableCredential{
+		ID:          "6f1c9d8e-5a5e-4c57-9d0a-8f3b2a1c4d5e",
+		CredType:    "Test Credential",
+		Credentials: map[string]string{"Name": "Alice", "Number": "12345"},
+		Subject:     common.Signature{DID: holder},
+		Issuer:      common.Signature{DID: issuer},
+	}
+	cred.SetValidityPeriod(time.Now(), time.Hour)
+	err = cred.CreateDigests()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cred.JWT, err = common.SignJWTCredential(issuerKeyURI, &cred)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reqs := []common.CredentialRequirement{{
+		CredType: "Test Credential",
+		Issuers:  []string{issuer},
+		Fields:   []string{"Name"},
+	}}
+	nonces := NewNonceStore(time.Minute)
+
+	present := func(disclosed common.VerifiableCredential) ([]common.VerifiableCredential, error) {
+		t.Helper()
+
+		encoded, err := common.EncodeJWTCredential(&disclosed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		nonce, err := nonces.Create()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		//decode the credential from its JWT, then sign the presentation as it is sent
+		unsigned, err := json.Marshal(map[string]interface{}{
+			"credentials": []string{encoded},
+			"nonce":       nonce,
+			"audience":    "did:example:verifier",
+			"timestamp":   time.Now().UTC(),
+			"holder":      common.Signature{DID: holder},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		vp := common.VerifiablePresentation{}
+		err = json.Unmarshal(unsigned, &vp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = common.SignStructWithSigner(holderSigner, &vp.Holder, &vp)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		sent, err := json.Marshal(&vp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		received := common.VerifiablePresentation{}
+		err = json.Unmarshal(sent, &received)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return VerifyPresentation(&received, "did:example:verifier", reqs, nonces)
+	}
+
+	disclosed, err := cred.Disclose([]string{"Name"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	matched, err := present(disclosed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if matched[0].Credentials["Name"] != "Alice" || len(matched[0].Credentials) != 1 {
+		t.Errorf("expected only the disclosed field, got %v", matched[0].Credentials)
+	}
+
+	disclosed.Credentials = map[string]string{"Name": "Mallory"}
+	_, err = present(disclosed)
+	if !errors.Is(err, common.ErrInvalidDisclosure) {
+		t.Errorf("expected ErrInvalidDisclosure, got %v", err)
+	}
+}
@@ -1,6 +1,7 @@
 package verifier
 
 import (
+	"errors"
 	"log"
 	"net/http"
 	"vcd/common"
@@ -8,19 +9,47 @@ import (
 
 type Verifier interface {
 	CreatePresentationRequest() common.PresentationRequest
-	VerifyCredentials(cred *common.VerifiableCredential) error
+	VerifyCredentials(creds []common.VerifiableCredential) error
 }
 
 type VerifierService struct {
 	Verifier      Verifier
+	DID           string
 	PrivateKeyURI string
+	Nonces        *NonceStore
+
+	common.SigningConfig
+
+	//operator of the trust registry the credential issuers must be accredited in, empty to accept any requested issuer
+	RegistryDID string
 }
 
 func (s VerifierService) GetVerifyHandler(w http.ResponseWriter, _ *http.Request) {
+	if s.Nonces == nil {
+		log.Println(ErrNoNonceStore)
+		common.SendInternalErrorResponse(w)
+		return
+	}
+
 	pres := s.Verifier.CreatePresentationRequest()
 	pres.Type = "verify"
+	pres.Audience = s.DID
+	pres.Entity = s.NewSignature(pres.Entity.DID)
+
+	var err error
+	pres.Nonce, err = s.Nonces.Create()
+	if errors.Is(err, ErrTooManyNonces) {
+		log.Println(err)
+		common.SendErrorResponse(w, http.StatusServiceUnavailable, "too many pending presentation requests, try again later")
+		return
+	}
+	if err != nil {
+		common.LogChainError("error creating nonce", err)
+		common.SendInternalErrorResponse(w)
+		return
+	}
 
-	err := common.SignStruct(s.PrivateKeyURI, &pres.Entity, &pres)
+	err = common.SignStruct(s.PrivateKeyURI, &pres.Entity, &pres)
 	if err != nil {
 		common.LogChainError("error signing presentation request", err)
 		common.SendInternalErrorResponse(w)
@@ -31,37 +60,34 @@ func (s VerifierService) GetVerifyHandler(w http.ResponseWriter, _ *http.Request
 }
 
 func (s VerifierService) PostVerifyHandler(w http.ResponseWriter, req *http.Request) {
-	cred := common.VerifiableCredential{}
+	vp := common.VerifiablePresentation{}
 
-	err := common.DecodeJSON(req.Body, &cred)
+	err := common.DecodeJSON(req.Body, &vp)
 	if err != nil {
 		log.Println(err)
 		common.SendErrorResponse(w, http.StatusBadRequest, "invalid JSON body")
 		return
 	}
 
-	err = common.VerifyStructSignature([]byte(cred.Subject.DID), &cred.Subject.Signature, &cred)
-	if err != nil {
-		log.Println(err)
-		common.SendErrorResponse(w, http.StatusUnauthorized, "error verifying subject signature")
-		return
-	}
+	pres := s.Verifier.CreatePresentationRequest()
 
-	issuerDID, err := common.LoadPublicKeyFromURI(cred.Issuer.DID)
+	creds, err := VerifyPresentation(&vp, s.DID, pres.Requirements, s.Nonces)
 	if err != nil {
-		log.Println(err)
-		common.SendInternalErrorResponse(w)
+		SendVerificationError(w, err)
 		return
 	}
 
-	err = common.VerifyStructSignature(issuerDID, &cred.Issuer.Signature, &cred)
-	if err != nil {
-		log.Println(err)
-		common.SendErrorResponse(w, http.StatusUnauthorized, "error verifying issuer signature")
-		return
+	if s.RegistryDID != "" {
+		for i := range creds {
+			err = common.CheckAccreditation(&creds[i], s.RegistryDID)
+			if err != nil {
+				SendVerificationError(w, err)
+				return
+			}
+		}
 	}
 
-	err = s.Verifier.VerifyCredentials(&cred)
+	err = s.Verifier.VerifyCredentials(creds)
 	if err != nil {
 		common.SendErrorResponse(w, http.StatusBadRequest, err.Error())
 		return